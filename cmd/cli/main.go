@@ -2,26 +2,217 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/c12i/babel-go/internal/library"
 )
 
 var CLI struct {
+	Charset  string        `help:"Charset the library is written with" default:"default" enum:"default,borges,alphanumeric,greek,cyrillic"`
+	Geometry GeometryFlags `embed:"" prefix:"geometry-" group:"Geometry"`
+	Scramble string        `help:"Key scrambling addresses so neighbouring pages are unrelated, empty to disable" env:"BABEL_SCRAMBLE_KEY"`
+
+	AddressFormat string `help:"Format addresses are printed and read in: base36, base62 or base85 hexagons, or the page number as an integer" default:"base36" enum:"base36,base62,base85,integer"`
+	CheckDigits   bool   `help:"Append two check digits to addresses, rejecting mistyped addresses when reading them" default:"false"`
+
 	Search SearchCmd `cmd:"" help:"Search for text in the library of Babel"`
+	Query  QueryCmd  `cmd:"" help:"Search for pages holding several phrases at once"`
 	Random RandomCmd `cmd:"" help:"Get a random location"`
 	Browse BrowseCmd `cmd:"" help:"Browse a page of a book in the library given its address"`
+	Locate LocateCmd `cmd:"" help:"Find the address of a full page of text"`
+	Run    RunCmd    `cmd:"" help:"Write a text too long for one page over several, read from a file"`
+	Verify VerifyCmd `cmd:"" help:"Check whether a text appears on the page at an address, failing when it does not"`
 }
 
 type Context struct {
 	Library *library.Library
+	Codec   library.AddressCodec
+}
+
+// address writes a location in the format set on the command line
+func (c *Context) address(location *library.Location) string {
+	address, err := c.Codec.Encode(*location)
+	if err != nil {
+		// only locations with malformed hexagons fail, which the library never returns
+		return location.String()
+	}
+	return address
+}
+
+// location reads an address in the format set on the command line
+func (c *Context) location(address string) (*library.Location, error) {
+	return c.Library.LocationFromAddress(address, c.Codec)
+}
+
+type GeometryFlags struct {
+	Walls   int `help:"Walls per hexagon"   default:"${walls}"`
+	Shelves int `help:"Shelves per wall"    default:"${shelves}"`
+	Books   int `help:"Books per shelf"     default:"${books}"`
+	Pages   int `help:"Pages per book"      default:"${pages}"`
+	Lines   int `help:"Lines per page"      default:"${lines}"`
+	Columns int `help:"Characters per line" default:"${columns}"`
+}
+
+func (g GeometryFlags) Geometry() library.Geometry {
+	return library.Geometry{
+		WallsPerHexagon: g.Walls,
+		ShelvesPerWall:  g.Shelves,
+		BooksPerShelf:   g.Books,
+		PagesPerBook:    g.Pages,
+		LinesPerPage:    g.Lines,
+		CharsPerLine:    g.Columns,
+	}
 }
 
 type SearchCmd struct {
-	Text   string `arg:"" help:"Text to search for"`
-	Offset int    `       help:"Starting position"  default:"0"`
-	Limit  int    `       help:"Number of results"  default:"10"`
+	Text       string   `arg:"" help:"Text to search for"`
+	Offset     int      `       help:"Starting position"  default:"0"`
+	Limit      int      `       help:"Number of results"  default:"10"`
+	Position   string   `       help:"Where the text sits on the page: anywhere, line-start, centre or <line>:<column>" default:"anywhere"`
+	Fill       string   `       help:"How the rest of the page is written: random, blank or english" default:"random" enum:"random,blank,english"`
+	Wildcards  bool     `       help:"Read ? in the text as any character and * as a run of up to 16 characters" default:"false"`
+	Exclude    []string `       help:"Text the rest of the page must not contain, repeat for several" sep:"none"`
+	Layout     string   `       help:"Shape the text is written in: run, vertical, diagonal or block, where each line of block text sits below the last" default:"run" enum:"run,vertical,diagonal,block"`
+	Wrap       bool     `       help:"Wrap the text at word boundaries, never splitting a word over two lines" default:"false"`
+	Shortest   bool     `       help:"Find the pages with the shortest addresses, the text ending an otherwise blank page" default:"false"`
+	Prefix     string   `       help:"Find pages whose hexagon starts with this base-36 prefix, e.g. babel"`
+	Sort       bool     `       help:"Sort each page of results by hexagon length, shortest first" default:"false"`
+	MaxHexagon int      `       help:"Only show the results of this page whose hexagon has at most this many characters, 0 for any" default:"0"`
+	Normalize  bool     `       help:"Rewrite characters outside the charset, e.g. accents, digits and symbols, listing each change" default:"false"`
+}
+
+// normalize rewrites the text into the library's charset, printing what it changed
+func (s *SearchCmd) normalize(lib *library.Library) (string, error) {
+	var kept string
+	if s.Wildcards {
+		kept += "?*"
+	}
+	if s.Layout == string(library.LayoutBlock) {
+		kept += "\n"
+	}
+	text, changes, err := lib.Normalize(s.Text, library.WithKept(kept))
+	if err != nil {
+		return "", err
+	}
+	for _, change := range changes {
+		fmt.Printf("Normalized %q at index %d to %q\n", change.From, change.Index, change.To)
+	}
+	if len(changes) > 0 {
+		fmt.Println()
+	}
+	return text, nil
+}
+
+// options converts the search flags into library search options
+func (s *SearchCmd) options() ([]library.SearchOption, error) {
+	opts := []library.SearchOption{
+		library.WithFill(library.Fill(s.Fill)),
+		library.WithExcluded(s.Exclude...),
+		library.WithLayout(library.Layout(s.Layout)),
+	}
+	if s.Wildcards {
+		opts = append(opts, library.WithWildcards())
+	}
+	if s.Wrap {
+		opts = append(opts, library.WithWordWrap())
+	}
+	if s.Shortest {
+		opts = append(opts, library.WithShortestAddress())
+	}
+	if s.Prefix != "" {
+		opts = append(opts, library.WithHexagonPrefix(s.Prefix))
+	}
+	switch s.Position {
+	case "anywhere":
+	case "line-start":
+		opts = append(opts, library.WithLineStart())
+	case "centre":
+		opts = append(opts, library.WithCentred())
+	default:
+		lineStr, columnStr, found := strings.Cut(s.Position, ":")
+		line, lineErr := strconv.Atoi(lineStr)
+		column, columnErr := strconv.Atoi(columnStr)
+		if !found || lineErr != nil || columnErr != nil {
+			return nil, fmt.Errorf("invalid position %q, expected anywhere, line-start, centre or <line>:<column>", s.Position)
+		}
+		opts = append(opts, library.WithPosition(line, column))
+	}
+	return opts, nil
+}
+
+type QueryCmd struct {
+	Phrases []string `arg:"" help:"Phrases to search for, each pinned to a place on the page with an optional @<line>:<column> suffix"`
+	Ordered bool     `       help:"Keep the phrases in the order given, from the top of the page down" default:"false"`
+	Offset  int      `       help:"Starting position"  default:"0"`
+	Limit   int      `       help:"Number of results"  default:"10"`
+	Fill    string   `       help:"How the rest of the page is written: random, blank or english" default:"random" enum:"random,blank,english"`
+	Exclude []string `       help:"Text the rest of the page must not contain, repeat for several" sep:"none"`
+}
+
+// query converts the phrase arguments into a library query
+func (q *QueryCmd) query() (library.Query, error) {
+	query := library.Query{Ordered: q.Ordered}
+	for _, arg := range q.Phrases {
+		phrase := library.Phrase{Text: arg}
+		if at := strings.LastIndex(arg, "@"); at >= 0 {
+			lineStr, columnStr, found := strings.Cut(arg[at+1:], ":")
+			line, lineErr := strconv.Atoi(lineStr)
+			column, columnErr := strconv.Atoi(columnStr)
+			if !found || lineErr != nil || columnErr != nil {
+				return library.Query{}, fmt.Errorf("invalid phrase %q, expected <phrase>@<line>:<column>", arg)
+			}
+			phrase = library.Phrase{Text: arg[:at], Line: line, Column: column}
+		}
+		query.Phrases = append(query.Phrases, phrase)
+	}
+	return query, nil
+}
+
+func (q *QueryCmd) Run(ctx *Context) error {
+	lib := ctx.Library
+
+	query, err := q.query()
+	if err != nil {
+		return err
+	}
+	opts := []library.SearchOption{
+		library.WithFill(library.Fill(q.Fill)),
+		library.WithExcluded(q.Exclude...),
+	}
+
+	totalCount, err := lib.GetQueryCount(query)
+	if err != nil {
+		return err
+	}
+	results, err := lib.SearchQueryPaginated(query, q.Offset, q.Limit, opts...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d phrases appear together on %s pages (%s), %d of them reachable through search.\n",
+		len(query.Phrases),
+		library.FormatScientific(totalCount),
+		library.FormatPower(totalCount, lib.Charset().Size()),
+		lib.ReachableQueryCount(query, opts...),
+	)
+	fmt.Printf("Showing %d results starting from %d:\n\n", len(results), q.Offset+1)
+
+	for i, result := range results {
+		fmt.Printf("  %d. %s\n", q.Offset+i+1, ctx.address(result.Location))
+		for j, match := range result.Matches {
+			snippet := result.Snippets[j]
+			fmt.Printf("     line %d, column %d: ...%s[%s]%s...\n",
+				match.Line, match.Column, snippet.Before, snippet.Match, snippet.After,
+			)
+		}
+	}
+
+	return nil
 }
 
 type RandomCmd struct {
@@ -31,28 +222,111 @@ type RandomCmd struct {
 func (s *SearchCmd) Run(ctx *Context) error {
 	lib := ctx.Library
 
-	totalCount := lib.GetOccurrenceCount(s.Text)
-	locations, err := lib.SearchPaginated(s.Text, s.Offset, s.Limit)
+	opts, err := s.options()
+	if err != nil {
+		return err
+	}
+
+	text := s.Text
+	if s.Normalize {
+		if text, err = s.normalize(lib); err != nil {
+			return err
+		}
+	}
+
+	results, err := lib.SearchPaginated(text, s.Offset, s.Limit, opts...)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Text '%s' appears in %d locations. Showing %d results starting from %d:\n\n",
-		s.Text, totalCount, len(locations), s.Offset+1)
+	// occurrence counts only cover text without wildcards written as a single run
+	switch {
+	case s.Wildcards:
+		fmt.Printf("Pattern '%s' is reachable on %d pages through search.\n",
+			text, lib.ReachableCount(text, opts...),
+		)
+	case s.Layout != string(library.LayoutRun):
+		fmt.Printf("Text '%s' written %s is reachable on %d pages through search.\n",
+			text, s.Layout, lib.ReachableCount(text, opts...),
+		)
+	default:
+		totalCount := lib.GetOccurrenceCount(text)
+		fmt.Printf("Text '%s' appears on %s pages (%s), %d of them reachable through search.\n",
+			text,
+			library.FormatScientific(totalCount),
+			library.FormatPower(totalCount, lib.Charset().Size()),
+			lib.ReachableCount(text, opts...),
+		)
+	}
 
-	for i, location := range locations {
-		fmt.Printf("  %d. %s\n", s.Offset+i+1, location.String())
+	// sorting and the hexagon length limit only apply to this page of results
+	if s.Sort {
+		library.SortByHexagonLength(results)
+	}
+	if s.MaxHexagon > 0 {
+		page := len(results)
+		results = slices.DeleteFunc(results, func(result *library.SearchResult) bool {
+			return result.HexagonLength() > s.MaxHexagon
+		})
+		fmt.Printf("Showing %d of %d results starting from %d, with hexagons of at most %d characters:\n\n",
+			len(results), page, s.Offset+1, s.MaxHexagon)
+	} else {
+		fmt.Printf("Showing %d results starting from %d:\n\n", len(results), s.Offset+1)
+	}
+	for _, result := range results {
+		fmt.Printf("  %d. %s\n", result.Variant+1, ctx.address(result.Location))
+		if result.Cells == nil {
+			fmt.Printf("     line %d, column %d: ...%s[%s]%s...\n",
+				result.Line, result.Column,
+				result.Snippet.Before, result.Snippet.Match, result.Snippet.After,
+			)
+			continue
+		}
+
+		pageContent, err := lib.Browse(result.Location)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("     line %d, column %d:\n", result.Line, result.Column)
+		charsPerLine := lib.Geometry().CharsPerLine
+		first, last := result.Cells[0]/charsPerLine, result.Cells[len(result.Cells)-1]/charsPerLine
+		for _, line := range highlightLines(pageContent, result.Cells, charsPerLine)[first : last+1] {
+			fmt.Printf("     %s\n", line)
+		}
 	}
 
 	return nil
 }
 
+// highlightLines splits a page into its lines, showing the characters at cells in reverse video
+func highlightLines(pageContent string, cells []int, charsPerLine int) []string {
+	highlighted := map[int]bool{}
+	for _, cell := range cells {
+		highlighted[cell] = true
+	}
+	lines := []string{}
+	var line strings.Builder
+	for i, char := range []rune(pageContent) {
+		if highlighted[i] {
+			fmt.Fprintf(&line, "\x1b[7m%c\x1b[0m", char)
+		} else {
+			line.WriteRune(char)
+		}
+		if (i+1)%charsPerLine == 0 {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+	}
+	return lines
+}
+
 type BrowseCmd struct {
-	Address string `arg:"" name:"address" help:"Period separated string of the address to browse in the library: <hexagon>.<wall>.<shelf>.<book>.<page>"`
+	Address string `arg:"" name:"address" help:"Address to browse in the library, by default a period separated string: <hexagon>.<wall>.<shelf>.<book>.<page>"`
+	Cells   []int  `help:"Page offsets of characters to highlight, printing the page line by line"`
 }
 
 func (s *BrowseCmd) Run(ctx *Context) error {
-	location, err := library.LocationFromString(s.Address)
+	location, err := ctx.location(s.Address)
 	if err != nil {
 		return err
 	}
@@ -60,12 +334,113 @@ func (s *BrowseCmd) Run(ctx *Context) error {
 	if err != nil {
 		return err
 	}
+	if len(s.Cells) > 0 {
+		for _, line := range highlightLines(pageContent, s.Cells, ctx.Library.Geometry().CharsPerLine) {
+			fmt.Printf("%s\n", line)
+		}
+		return nil
+	}
 	fmt.Printf("%s", pageContent)
 	return nil
 }
 
+type LocateCmd struct {
+	File string `arg:"" optional:"" help:"File holding the page, standard input when omitted or -"`
+	Pad  bool   `help:"Pad pages shorter than a full page with the charset's first symbol" default:"false"`
+}
+
+// readInput reads a file, or standard input when file is empty or -
+func readInput(file string) ([]byte, error) {
+	if file == "" || file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+func (l *LocateCmd) Run(ctx *Context) error {
+	page, err := readInput(l.File)
+	if err != nil {
+		return err
+	}
+
+	locate := ctx.Library.Locate
+	if l.Pad {
+		locate = ctx.Library.LocatePadded
+	}
+	location, err := locate(string(page))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", ctx.address(location))
+	return nil
+}
+
+type RunCmd struct {
+	File    string   `arg:"" optional:"" help:"File holding the text, standard input when omitted or -"`
+	Variant int      `help:"Variant of the pages to write the text on" default:"0"`
+	Fill    string   `help:"How the rest of the last page is written: random, blank or english" default:"random" enum:"random,blank,english"`
+	Exclude []string `help:"Text the rest of the last page must not contain, repeat for several" sep:"none"`
+	Browse  bool     `help:"Print each page of the run in turn" default:"false"`
+}
+
+func (r *RunCmd) Run(ctx *Context) error {
+	text, err := readInput(r.File)
+	if err != nil {
+		return err
+	}
+	run, err := ctx.Library.SearchRun(
+		strings.TrimRight(string(text), "\r\n"), r.Variant,
+		library.WithFill(library.Fill(r.Fill)),
+		library.WithExcluded(r.Exclude...),
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Text runs over %d pages, starting at %s\n\n", len(run.Pages), ctx.address(run.Start()))
+	start := 0
+	for i, page := range run.Pages {
+		fmt.Printf("  %d. %s\n", i+1, ctx.address(page.Location))
+		fmt.Printf("     characters %d to %d\n", start+1, start+page.Length)
+		start += page.Length
+		if !r.Browse {
+			continue
+		}
+		pageContent, err := ctx.Library.Browse(page.Location)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s\n\n", pageContent)
+	}
+	return nil
+}
+
+type VerifyCmd struct {
+	Address string `arg:"" help:"Address of the page, by default <hexagon>.<wall>.<shelf>.<book>.<page>"`
+	Text    string `arg:"" help:"Text to look for, ignoring case and line breaks"`
+}
+
+func (v *VerifyCmd) Run(ctx *Context) error {
+	location, err := ctx.location(v.Address)
+	if err != nil {
+		return err
+	}
+	matches, err := ctx.Library.Verify(location, v.Text)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("text '%s' does not appear on the page", v.Text)
+	}
+	fmt.Printf("Text '%s' appears %d time(s) on the page:\n", v.Text, len(matches))
+	for _, match := range matches {
+		fmt.Printf("  line %d, column %d\n", match.Line, match.Column)
+	}
+	return nil
+}
+
 func (r *RandomCmd) Run(ctx *Context) error {
-	location := library.RandomLocation()
+	location := ctx.Library.RandomLocation()
 	if r.Browse {
 		pageContent, err := ctx.Library.Browse(location)
 		if err != nil {
@@ -73,19 +448,53 @@ func (r *RandomCmd) Run(ctx *Context) error {
 		}
 		fmt.Printf("%s", pageContent)
 	} else {
-		fmt.Printf("%s\n", location.String())
+		fmt.Printf("%s\n", ctx.address(location))
 	}
 	return nil
 }
 
 func main() {
+	geometry := library.DefaultGeometry
 	ctx := kong.Parse(
 		&CLI,
 		kong.Name("babel"),
 		kong.Description("Library of Babel CLI - Search and browse the infinite library"),
 		kong.UsageOnError(),
+		kong.Vars{
+			"walls":   strconv.Itoa(geometry.WallsPerHexagon),
+			"shelves": strconv.Itoa(geometry.ShelvesPerWall),
+			"books":   strconv.Itoa(geometry.BooksPerShelf),
+			"pages":   strconv.Itoa(geometry.PagesPerBook),
+			"lines":   strconv.Itoa(geometry.LinesPerPage),
+			"columns": strconv.Itoa(geometry.CharsPerLine),
+		},
 	)
-	err := ctx.Run(&Context{Library: library.NewLibrary()})
+	charset, err := library.CharsetByName(CLI.Charset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := []library.Option{
+		library.WithCharset(charset),
+		library.WithGeometry(CLI.Geometry.Geometry()),
+	}
+	if CLI.Scramble != "" {
+		opts = append(opts, library.WithScrambling(CLI.Scramble))
+	}
+	lib, err := library.NewLibrary(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	codec, err := library.AddressCodecByName(CLI.AddressFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if CLI.CheckDigits {
+		codec = library.WithCheckDigits(codec)
+	}
+	err = ctx.Run(&Context{Library: lib, Codec: codec})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -10,14 +10,22 @@ import (
 
 func main() {
 	logger := log.New(os.Stdout, "[BABEL] ", log.Ldate|log.Ltime|log.Lshortfile)
-	library := library.NewLibrary()
+	opts := []library.Option{}
+	// a fixed key keeps scrambled addresses stable across deployments
+	if key := os.Getenv("BABEL_SCRAMBLE_KEY"); key != "" {
+		opts = append(opts, library.WithScrambling(key))
+	}
+	library, err := library.NewLibrary(opts...)
+	if err != nil {
+		logger.Fatalf("failed to build library: %v", err)
+	}
 
 	server := web.NewServer(
 		web.NewHandler(library, logger),
 		logger,
 	)
 
-	err := server.Start()
+	err = server.Start()
 	if err != nil {
 		logger.Fatalf("failed to start server: %v", err)
 	}
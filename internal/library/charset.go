@@ -0,0 +1,144 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Charset is the ordered set of symbols the library's pages are written with.
+// A symbol's position in the set is its digit value when a page is read as a base-N number,
+// so the first symbol is the "zero" of the library.
+type Charset struct {
+	name    string
+	symbols []rune
+	index   map[rune]int
+}
+
+// Preset charsets
+var (
+	// The 29 symbols used by libraryofbabel.info: space, a-z, comma and period
+	CharsetDefault = mustCharset("default", " abcdefghijklmnopqrstuvwxyz,.")
+	// Borges' 25 orthographic symbols: space, comma, period and 22 letters (no k, q, w or x)
+	CharsetBorges = mustCharset("borges", " abcdefghijlmnoprstuvyz,.")
+	// Mixed case letters and digits alongside space, comma and period
+	CharsetAlphanumeric = mustCharset(
+		"alphanumeric",
+		" abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789,.",
+	)
+	// The lowercase Greek alphabet, final sigma included, with space, comma and period
+	CharsetGreek = mustCharset("greek", " αβγδεζηθικλμνξοπρσςτυφχψω,.")
+	// The lowercase Russian Cyrillic alphabet with space, comma and period
+	CharsetCyrillic = mustCharset("cyrillic", " абвгдеёжзийклмнопрстуфхцчшщъыьэюя,.")
+)
+
+var presetCharsets = map[string]*Charset{
+	CharsetDefault.name:      CharsetDefault,
+	CharsetBorges.name:       CharsetBorges,
+	CharsetAlphanumeric.name: CharsetAlphanumeric,
+	CharsetGreek.name:        CharsetGreek,
+	CharsetCyrillic.name:     CharsetCyrillic,
+}
+
+// NewCharset builds a charset from the given symbols, in digit order.
+// Symbols must be unique, printable and there must be at least two of them.
+func NewCharset(name, symbols string) (*Charset, error) {
+	if name == "" {
+		return nil, errors.New("charset name should not be empty")
+	}
+	if !utf8.ValidString(symbols) {
+		return nil, errors.New("charset symbols must be valid UTF-8")
+	}
+
+	runes := []rune(symbols)
+	if len(runes) < 2 {
+		return nil, fmt.Errorf("charset must contain at least 2 symbols, got %d", len(runes))
+	}
+
+	index := make(map[rune]int, len(runes))
+	for i, r := range runes {
+		if !unicode.IsPrint(r) {
+			return nil, fmt.Errorf("charset symbol %q at index %d is not printable", r, i)
+		}
+		if _, exists := index[r]; exists {
+			return nil, fmt.Errorf("charset symbol %q is duplicated at index %d", r, i)
+		}
+		index[r] = i
+	}
+
+	return &Charset{
+		name:    name,
+		symbols: runes,
+		index:   index,
+	}, nil
+}
+
+// CharsetByName looks up one of the preset charsets
+func CharsetByName(name string) (*Charset, error) {
+	charset, ok := presetCharsets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown charset %q, available: %v", name, CharsetNames())
+	}
+	return charset, nil
+}
+
+// CharsetNames lists the names of the preset charsets in alphabetical order
+func CharsetNames() []string {
+	names := make([]string, 0, len(presetCharsets))
+	for name := range presetCharsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mustCharset(name, symbols string) *Charset {
+	charset, err := NewCharset(name, symbols)
+	if err != nil {
+		panic(err)
+	}
+	return charset
+}
+
+func (c Charset) Name() string {
+	return c.name
+}
+
+// Size is the number of symbols in the charset, which is the base of the library's page numbers
+func (c Charset) Size() int {
+	return len(c.symbols)
+}
+
+// Symbols returns a copy of the charset's symbols in digit order
+func (c Charset) Symbols() []rune {
+	return slices.Clone(c.symbols)
+}
+
+func (c Charset) Contains(r rune) bool {
+	_, ok := c.index[r]
+	return ok
+}
+
+func (c Charset) String() string {
+	return string(c.symbols)
+}
+
+// Fold maps characters missing from the charset onto their lower or upper case
+// counterpart when the charset has one, leaving every other character untouched
+func (c Charset) Fold(text string) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if c.Contains(r) {
+			continue
+		}
+		if lower := unicode.ToLower(r); c.Contains(lower) {
+			runes[i] = lower
+		} else if upper := unicode.ToUpper(r); c.Contains(upper) {
+			runes[i] = upper
+		}
+	}
+	return string(runes)
+}
@@ -0,0 +1,145 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING Charset construction and lookup
+*/
+
+func TestNewCharsetValid(t *testing.T) {
+	charset, err := NewCharset("binary", "01")
+	if err != nil {
+		t.Fatalf("failed to build charset: %v", err)
+	}
+	if size := charset.Size(); size != 2 {
+		t.Errorf("expected size 2, got %d", size)
+	}
+	if !charset.Contains('1') || charset.Contains('2') {
+		t.Errorf("unexpected charset membership for %q", charset)
+	}
+}
+
+func TestNewCharsetInvalid(t *testing.T) {
+	tests := map[string]struct {
+		name    string
+		symbols string
+	}{
+		"empty name":        {name: "", symbols: "ab"},
+		"too few symbols":   {name: "single", symbols: "a"},
+		"duplicate symbols": {name: "dup", symbols: "abca"},
+		"non printable":     {name: "newline", symbols: "ab\n"},
+		"invalid utf8":      {name: "utf8", symbols: "ab\xff"},
+	}
+
+	for name, tt := range tests {
+		if _, err := NewCharset(tt.name, tt.symbols); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+}
+
+func TestCharsetByName(t *testing.T) {
+	for _, name := range CharsetNames() {
+		charset, err := CharsetByName(name)
+		if err != nil {
+			t.Errorf("failed to look up preset %q: %v", name, err)
+			continue
+		}
+		if charset.Name() != name {
+			t.Errorf("got charset %q, want %q", charset.Name(), name)
+		}
+	}
+
+	if _, err := CharsetByName("klingon"); err == nil {
+		t.Errorf("got nil, expected err for unknown charset")
+	}
+}
+
+func TestCharsetPresetSizes(t *testing.T) {
+	expected := map[*Charset]int{
+		CharsetDefault:      29,
+		CharsetBorges:       25,
+		CharsetAlphanumeric: 65,
+		CharsetGreek:        28,
+		CharsetCyrillic:     36,
+	}
+	for charset, size := range expected {
+		if got := charset.Size(); got != size {
+			t.Errorf("%s: expected %d symbols, got %d", charset.Name(), size, got)
+		}
+	}
+}
+
+func TestCharsetFold(t *testing.T) {
+	if got := CharsetDefault.Fold("Hello World!"); got != "hello world!" {
+		t.Errorf("got %q, want %q", got, "hello world!")
+	}
+	if got := CharsetAlphanumeric.Fold("Hello World"); got != "Hello World" {
+		t.Errorf("got %q, want %q", got, "Hello World")
+	}
+	if got := CharsetGreek.Fold("ΑΛΦΑ"); got != "αλφα" {
+		t.Errorf("got %q, want %q", got, "αλφα")
+	}
+}
+
+func TestNewLibraryWithNilCharset(t *testing.T) {
+	if _, err := NewLibrary(WithCharset(nil)); err == nil {
+		t.Errorf("got nil, expected err")
+	}
+}
+
+/*
+	TESTING search and browse round trips per charset
+*/
+
+func TestLibraryCharsetRoundTrip(t *testing.T) {
+	texts := map[*Charset]string{
+		CharsetDefault:      "hello world",
+		CharsetBorges:       "la biblioteca es total",
+		CharsetAlphanumeric: "Room 101, Floor 3.",
+		CharsetGreek:        "εν αρχη ην ο λογος",
+		CharsetCyrillic:     "в начале было слово",
+	}
+
+	for charset, text := range texts {
+		library := newTestLibrary(t, WithCharset(charset))
+
+		number, err := library.generateBase29Number(text, 3)
+		if err != nil {
+			t.Errorf("%s: failed to encode text: %v", charset.Name(), err)
+			continue
+		}
+		page := library.base29NumberToString(number)
+		if !strings.Contains(page, text) {
+			t.Errorf("%s: page does not contain %q", charset.Name(), text)
+		}
+		if length := len([]rune(page)); length != DefaultGeometry.CharsPerPage() {
+			t.Errorf("%s: expected %d characters, got %d", charset.Name(), DefaultGeometry.CharsPerPage(), length)
+		}
+
+		location := locationFromBase29Number(number, DefaultGeometry)
+		parsed, err := library.LocationFromString(location.String())
+		if err != nil {
+			t.Errorf("%s: failed to parse address: %v", charset.Name(), err)
+			continue
+		}
+		browsed, err := library.Browse(parsed)
+		if err != nil {
+			t.Errorf("%s: failed to browse: %v", charset.Name(), err)
+			continue
+		}
+		if browsed != page {
+			t.Errorf("%s: browsed page differs from searched page", charset.Name())
+		}
+	}
+}
+
+func TestLibraryCharsetRejectsForeignSymbols(t *testing.T) {
+	library := newTestLibrary(t, WithCharset(CharsetBorges))
+	if _, err := library.generateBase29Number("kiwi", 0); err == nil {
+		t.Errorf("got nil, expected err for letters outside the borges charset")
+	}
+}
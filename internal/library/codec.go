@@ -0,0 +1,255 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+const (
+	alphabet36 = "0123456789abcdefghijklmnopqrstuvwxyz"
+	alphabet62 = alphabet36 + "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// the characters of Z85, with ~ standing in for the period that separates an address's parts
+	alphabet85 = alphabet62 + "-:+=^!/*?&<>()[]{}@%$#~"
+	// modulus of the check digits, the prime below 100 so that every mistyped character
+	// and every swap of two neighbouring printable characters changes them
+	checkModulus = 97
+)
+
+// AddressCodec writes locations as addresses and parses them back, see the preset
+// codecs Base36Codec, Base62Codec, Base85Codec and IntegerCodec
+type AddressCodec interface {
+	// Encode writes a location as an address
+	Encode(location Location) (string, error)
+	// Decode parses an address into a location laid out in geometry. Whether the
+	// location is within a library depends on its charset, see Library.LocationFromAddress.
+	Decode(address string, geometry Geometry) (*Location, error)
+}
+
+var (
+	// Base36Codec writes "<hexagon>.<wall>.<shelf>.<book>.<page>", the hexagon in
+	// base-36, the format of Location.String and LocationFromString
+	Base36Codec AddressCodec = hexagonCodec{alphabet: alphabet36}
+	// Base62Codec writes the hexagon in base-62, digits then lower and upper case letters
+	Base62Codec AddressCodec = hexagonCodec{alphabet: alphabet62}
+	// Base85Codec writes the hexagon in base-85, the Z85 characters with ~ in place of the period
+	Base85Codec AddressCodec = hexagonCodec{alphabet: alphabet85}
+	// IntegerCodec writes the page number in base-10, a single integer
+	IntegerCodec AddressCodec = integerCodec{}
+)
+
+// ErrCheckDigits reports an address whose check digits do not match, see WithCheckDigits
+var ErrCheckDigits = errors.New("address does not match its check digits, it was likely mistyped")
+
+var presetCodecs = map[string]AddressCodec{
+	"base36":  Base36Codec,
+	"base62":  Base62Codec,
+	"base85":  Base85Codec,
+	"integer": IntegerCodec,
+}
+
+// AddressCodecByName returns the preset codec with the given name
+func AddressCodecByName(name string) (AddressCodec, error) {
+	codec, ok := presetCodecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown address codec %q, available: %v", name, AddressCodecNames())
+	}
+	return codec, nil
+}
+
+// AddressCodecNames lists the names of the preset codecs in alphabetical order
+func AddressCodecNames() []string {
+	names := make([]string, 0, len(presetCodecs))
+	for name := range presetCodecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LocationFromAddress parses an address written by codec, laid out in the library's
+// geometry, rejecting locations past the end of the library
+func (l Library) LocationFromAddress(address string, codec AddressCodec) (*Location, error) {
+	location, err := codec.Decode(address, l.geometry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.pageNumber(location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// hexagonCodec writes addresses in five parts, the hexagon in the digits of alphabet
+type hexagonCodec struct {
+	alphabet string
+}
+
+func (c hexagonCodec) Encode(location Location) (string, error) {
+	hexagon, ok := location.hexagonNumber()
+	if !ok || hexagon.Sign() < 0 {
+		return "", errors.New("invalid hexagon string format")
+	}
+	return fmt.Sprintf("%s.%d.%d.%d.%d",
+		encodeDigits(hexagon, c.alphabet), location.Wall, location.Shelf, location.Book, location.Page), nil
+}
+
+func (c hexagonCodec) Decode(address string, geometry Geometry) (*Location, error) {
+	if c.alphabet == alphabet36 {
+		return locationFromString(address, geometry)
+	}
+	parts := strings.SplitN(address, ".", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("address is not of valid length, expected %d, got %d", 5, len(parts))
+	}
+	n, err := decodeDigits(parts[0], c.alphabet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hexagon: %w", err)
+	}
+	// the rest of the address is written as in base-36 addresses
+	return locationFromString(n.Text(36)+"."+parts[1], geometry)
+}
+
+// integerCodec writes addresses as the page number in base-10
+type integerCodec struct{}
+
+func (integerCodec) Encode(location Location) (string, error) {
+	n, err := location.ToBigInt()
+	if err != nil {
+		return "", err
+	}
+	return n.Text(10), nil
+}
+
+func (integerCodec) Decode(address string, geometry Geometry) (*Location, error) {
+	n, ok := new(big.Int).SetString(address, 10)
+	if !ok || n.Sign() < 0 || n.Text(10) != address {
+		return nil, errors.New("invalid address: must be a non-negative integer without leading zeros")
+	}
+	return locationFromBase29Number(n, geometry), nil
+}
+
+// WithCheckDigits appends two check digits to the addresses codec writes, after a period,
+// and rejects addresses whose check digits do not match. A single mistyped character or
+// two neighbouring characters swapped always change the check digits.
+func WithCheckDigits(codec AddressCodec) AddressCodec {
+	return checkedCodec{codec: codec}
+}
+
+type checkedCodec struct {
+	codec AddressCodec
+}
+
+func (c checkedCodec) Encode(location Location) (string, error) {
+	address, err := c.codec.Encode(location)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%02d", address, checkDigits(address)), nil
+}
+
+func (c checkedCodec) Decode(address string, geometry Geometry) (*Location, error) {
+	separator := strings.LastIndexByte(address, '.')
+	if separator < 0 {
+		return nil, errors.New("address has no check digits")
+	}
+	check := address[separator+1:]
+	if len(check) != 2 || strings.Trim(check, "0123456789") != "" {
+		return nil, fmt.Errorf("address check digits must be two digits, got %q", check)
+	}
+	if address = address[:separator]; check != fmt.Sprintf("%02d", checkDigits(address)) {
+		return nil, ErrCheckDigits
+	}
+	return c.codec.Decode(address, geometry)
+}
+
+// checkDigits is the weighted sum of address's bytes modulo checkModulus, weights running
+// from 1 to checkModulus-1 and around again. The modulus is prime and larger than the
+// difference between any two printable characters, so changing one byte, or swapping
+// two neighbouring ones, changes the sum.
+func checkDigits(address string) int {
+	sum := 0
+	for i := range len(address) {
+		weight := i%(checkModulus-1) + 1
+		sum = (sum + weight*int(address[i])) % checkModulus
+	}
+	return sum
+}
+
+// the largest power of a base that fits in a uint64 and how many digits it spans
+func digitChunk(base int) (uint64, int) {
+	chunk, digits := uint64(base), 1
+	for chunk <= (1<<63)/uint64(base) {
+		chunk *= uint64(base)
+		digits++
+	}
+	return chunk, digits
+}
+
+// encodeDigits writes n in the base of alphabet, its characters being the digits in order
+func encodeDigits(n *big.Int, alphabet string) string {
+	base := len(alphabet)
+	if base <= big.MaxBase && alphabet62[:base] == alphabet {
+		return n.Text(base)
+	}
+
+	// peel off as many digits at once as a uint64 holds, then split them up
+	chunk, width := digitChunk(base)
+	divisor := new(big.Int).SetUint64(chunk)
+	rest, remainder := new(big.Int).Set(n), new(big.Int)
+	digits := []byte{}
+	for rest.Sign() > 0 {
+		rest.DivMod(rest, divisor, remainder)
+		value := remainder.Uint64()
+		for range width {
+			digits = append(digits, alphabet[value%uint64(base)])
+			value /= uint64(base)
+		}
+	}
+	// least significant first so far, without leading zeros
+	for len(digits) > 1 && digits[len(digits)-1] == alphabet[0] {
+		digits = digits[:len(digits)-1]
+	}
+	if len(digits) == 0 {
+		return alphabet[:1]
+	}
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+	return string(digits)
+}
+
+// decodeDigits reads text written by encodeDigits, rejecting leading zeros so every
+// number has a single spelling
+func decodeDigits(text, alphabet string) (*big.Int, error) {
+	base := len(alphabet)
+	if text == "" {
+		return nil, errors.New("should not be empty")
+	}
+	if len(text) > 1 && text[0] == alphabet[0] {
+		return nil, errors.New("should not have leading zeros")
+	}
+
+	chunk, width := digitChunk(base)
+	n := big.NewInt(0)
+	for start := 0; start < len(text); start += width {
+		end := min(start+width, len(text))
+		value, scale := uint64(0), uint64(1)
+		for i := start; i < end; i++ {
+			digit := strings.IndexByte(alphabet, text[i])
+			if digit < 0 {
+				return nil, fmt.Errorf("invalid character %q for base %d", text[i], base)
+			}
+			value = value*uint64(base) + uint64(digit)
+			scale *= uint64(base)
+		}
+		if end-start < width {
+			chunk = scale
+		}
+		n.Mul(n, new(big.Int).SetUint64(chunk))
+		n.Add(n, new(big.Int).SetUint64(value))
+	}
+	return n, nil
+}
@@ -0,0 +1,157 @@
+package library
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+/*
+	TESTING address codecs and check digits
+*/
+
+func TestAddressCodecs(t *testing.T) {
+	location, err := LocationFromString("3a7f.2.3.15.204")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	page, _ := location.ToBigInt()
+	tests := map[string]struct {
+		codec AddressCodec
+		want  string
+	}{
+		"base36":  {Base36Codec, "3a7f.2.3.15.204"},
+		"base62":  {Base62Codec, "DQT.2.3.15.204"},
+		"base85":  {Base85Codec, "lhp.2.3.15.204"},
+		"integer": {IntegerCodec, page.Text(10)},
+	}
+	for name, test := range tests {
+		address, err := test.codec.Encode(*location)
+		if err != nil {
+			t.Fatalf("%s: failed to encode: %v", name, err)
+		}
+		if address != test.want {
+			t.Errorf("%s: got %s, want %s", name, address, test.want)
+		}
+		decoded, err := defaultLibrary.LocationFromAddress(address, test.codec)
+		if err != nil {
+			t.Fatalf("%s: failed to decode %s: %v", name, address, err)
+		}
+		if *decoded != *location {
+			t.Errorf("%s: got %s, want %s", name, decoded, location)
+		}
+	}
+}
+
+func TestAddressCodecsRoundTrip(t *testing.T) {
+	locations := []*Location{{Hexagon: "0", Page: 1}, defaultLibrary.RandomLocation()}
+	for range 20 {
+		locations = append(locations, defaultLibrary.RandomLocation())
+	}
+	small := newTestLibrary(t, WithGeometry(smallGeometry))
+	for _, name := range AddressCodecNames() {
+		codec, err := AddressCodecByName(name)
+		if err != nil {
+			t.Fatalf("failed to get codec %s: %v", name, err)
+		}
+		for _, codec := range []AddressCodec{codec, WithCheckDigits(codec)} {
+			for _, location := range append(locations, small.RandomLocation()) {
+				library := defaultLibrary
+				if location.Geometry() == smallGeometry {
+					library = small
+				}
+				address, err := codec.Encode(*location)
+				if err != nil {
+					t.Fatalf("%s: failed to encode %s: %v", name, location, err)
+				}
+				decoded, err := library.LocationFromAddress(address, codec)
+				if err != nil {
+					t.Fatalf("%s: failed to decode %s: %v", name, address, err)
+				}
+				if !decoded.Equals(*location) {
+					t.Errorf("%s: got %s from %s, want %s", name, decoded, address, location)
+				}
+			}
+		}
+	}
+}
+
+func TestAddressCheckDigitsCatchTypos(t *testing.T) {
+	location := defaultLibrary.RandomLocation()
+	for _, name := range AddressCodecNames() {
+		codec, _ := AddressCodecByName(name)
+		codec = WithCheckDigits(codec)
+		address, err := codec.Encode(*location)
+		if err != nil {
+			t.Fatalf("%s: failed to encode: %v", name, err)
+		}
+
+		// every character mistyped as any other, past where the weights run around again
+		// and in the last parts, which are short enough to check in full
+		for i := range len(address) {
+			if i >= 3*checkModulus && i < len(address)-30 {
+				continue
+			}
+			for _, typo := range []byte(alphabet85 + ".") {
+				if typo == address[i] {
+					continue
+				}
+				mistyped := address[:i] + string(typo) + address[i+1:]
+				if _, err := defaultLibrary.LocationFromAddress(mistyped, codec); err == nil {
+					t.Fatalf("%s: accepted %s mistyped from %s", name, mistyped, address)
+				}
+			}
+		}
+		// every pair of neighbouring characters swapped
+		for i := range len(address) - 1 {
+			if address[i] == address[i+1] {
+				continue
+			}
+			swapped := address[:i] + string(address[i+1]) + string(address[i]) + address[i+2:]
+			if _, err := defaultLibrary.LocationFromAddress(swapped, codec); err == nil {
+				t.Fatalf("%s: accepted %s swapped from %s", name, swapped, address)
+			}
+		}
+	}
+}
+
+func TestAddressCodecsInvalid(t *testing.T) {
+	tests := map[string]struct {
+		codec   AddressCodec
+		address string
+	}{
+		"base62 leading zeros":   {Base62Codec, "0DQT.2.3.15.204"},
+		"base62 invalid":         {Base62Codec, "DQ_T.2.3.15.204"},
+		"base62 missing parts":   {Base62Codec, "DQT"},
+		"base62 invalid wall":    {Base62Codec, "DQT.4.3.15.204"},
+		"base85 period":          {Base85Codec, "lh.p.2.3.15.204"},
+		"base85 empty hexagon":   {Base85Codec, ".2.3.15.204"},
+		"integer leading zeros":  {IntegerCodec, "0042"},
+		"integer negative":       {IntegerCodec, "-42"},
+		"integer not a number":   {IntegerCodec, "4e2"},
+		"check digits missing":   {WithCheckDigits(IntegerCodec), "42"},
+		"check digits one digit": {WithCheckDigits(Base36Codec), "3a7f.2.3.15.204.7"},
+		"check digits letters":   {WithCheckDigits(Base36Codec), "3a7f.2.3.15.204.ab"},
+		"check digits signed":    {WithCheckDigits(Base36Codec), "3a7f.2.3.15.204.+9"},
+	}
+	for name, test := range tests {
+		if _, err := defaultLibrary.LocationFromAddress(test.address, test.codec); err == nil {
+			t.Errorf("%s: expected error for %s", name, test.address)
+		}
+	}
+
+	// numbers past the last page are out of the library
+	end := new(big.Int).Add(defaultLibrary.MaxHexagon(), big.NewInt(1))
+	for _, codec := range []AddressCodec{Base62Codec, Base85Codec} {
+		hexagon := codec.(hexagonCodec)
+		address := encodeDigits(end, hexagon.alphabet) + ".0.0.0.1"
+		var rangeErr *OutOfRangeError
+		if _, err := defaultLibrary.LocationFromAddress(address, codec); !errors.As(err, &rangeErr) {
+			t.Errorf("%s: expected OutOfRangeError, got %v", address[:8], err)
+		}
+	}
+
+	if _, err := AddressCodecByName("base64"); err == nil {
+		t.Error("expected error for an unknown codec")
+	}
+}
@@ -0,0 +1,227 @@
+package library
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Variants seed math/rand, which reduces seeds modulo 2^31-1 and treats 0 as a fixed
+// constant, so no more than 2^31-2 distinct pages can be generated for a single text
+const maxReachableVariants = math.MaxInt32 - 1
+
+// GetOccurrenceCount is the exact number of distinct pages in the library containing text.
+// A page holding the text more than once is counted once.
+//
+// Pages avoiding the text are counted with the Guibas-Odlyzko recurrence over the text's
+// autocorrelation, the set of shifts at which it overlaps itself. For a text that cannot
+// overlap itself this is close to positions × base^(free characters); periodic texts such as
+// "aaa" or "abab" pack more occurrences into fewer pages and the recurrence accounts for that.
+// Texts with characters outside the charset, or longer than a page, occur nowhere.
+func (l Library) GetOccurrenceCount(text string) *big.Int {
+	pattern := []rune(l.charset.Fold(text))
+	charsPerPage := l.geometry.CharsPerPage()
+	if len(pattern) > charsPerPage {
+		return big.NewInt(0)
+	}
+	for _, char := range pattern {
+		if !l.charset.Contains(char) {
+			return big.NewInt(0)
+		}
+	}
+
+	avoiding := l.countPagesAvoiding(pattern)
+	return avoiding.Sub(l.PageCount(), avoiding)
+}
+
+// ReachableCount estimates how many of the pages containing text can be reached through
+// variant indexes, which is the number of results a search with the same options yields.
+// Options placing the text at a position count the pages holding it there, and blank
+// filled pages count the positions the text can take. Layouts other than LayoutRun count
+// the places their shape fits times the pages around it, and word wrapped text the pages
+// around it wrapped from each place it fits. A hexagon prefix counts the places below the
+// characters it fixes times the pages around them. Shelving options divide the count by the
+// places the pinned parts of the address can take, except for short addresses whose every
+// variant is shelved. Excluded texts are not taken into
+// account, so the count is an upper bound when they are set.
+func (l Library) ReachableCount(text string, opts ...SearchOption) int {
+	config, err := newSearchConfig(opts)
+	if err != nil || l.validateSearch(text, &config) != nil {
+		return 0
+	}
+	return l.reachableCount(text, config)
+}
+
+func (l Library) reachableCount(text string, config searchConfig) int {
+	length := config.textLength(text)
+	var count *big.Int
+	switch {
+	case config.shortest:
+		count = l.shortestCount(length, config)
+	case config.prefix != "":
+		count = l.vanityCount(length, config)
+	case config.wordWrap:
+		count = l.wrapCount(config)
+	case config.layout != LayoutRun:
+		s := config.layout.shape([]rune(text), nil)
+		count = big.NewInt(int64(config.position.shapeCandidates(s, l.geometry)))
+		if config.fill != FillBlank {
+			free := big.NewInt(int64(l.geometry.CharsPerPage() - length))
+			count.Mul(count, new(big.Int).Exp(l.base, free, nil))
+		}
+	case config.fill == FillBlank:
+		count = big.NewInt(int64(config.position.candidates(length, l.geometry)))
+		// a blank text looks the same wherever it sits on a blank page
+		if !config.wildcards && strings.Trim(l.charset.Fold(text), string(l.charset.symbols[0])) == "" {
+			count.SetInt64(1)
+		}
+	case config.position.kind == positionAnywhere && !config.wildcards:
+		count = l.GetOccurrenceCount(text)
+	default:
+		count = config.position.count(length, l.geometry, l.base)
+	}
+	// patterns count as if every run wildcard were empty, each character wildcard
+	// multiplying the pages by the charset's size
+	wildcards := big.NewInt(int64(config.singleWildcards(text)))
+	count.Mul(count, new(big.Int).Exp(l.base, wildcards, nil))
+	// shelving lands a page on one of its places, rounding up to keep reachable pages
+	if places := big.NewInt(int64(config.shelving.places(l.geometry))); places.Cmp(big.NewInt(1)) > 0 && !config.shortest {
+		count.Add(count, places).Sub(count, big.NewInt(1)).Div(count, places)
+	}
+
+	if count.Cmp(big.NewInt(maxReachableVariants)) > 0 {
+		return maxReachableVariants
+	}
+	return int(count.Int64())
+}
+
+// countPagesAvoiding counts the pages that do not contain pattern. With c(z) the pattern's
+// autocorrelation polynomial and m its length, the generating function of strings avoiding it
+// is c(z) / (z^m + (1 - base·z)·c(z)), which unrolls into a linear recurrence of order m.
+//
+// Writing e(z) = (1 - base·z)·a(z) for the avoiding strings a(z), each step reads
+// e_t = c_t - a_(t-m) - Σ e_(t-k) over the shifts k > 0 the pattern overlaps itself at,
+// then a_t = e_t + base·a_(t-1). Those shifts fall into a few arithmetic progressions, all
+// of them for a periodic pattern such as "aaa", and each progression's sum is read off
+// running sums of e taken in steps of its difference, so a step costs a few additions
+// however much the pattern overlaps itself.
+func (l Library) countPagesAvoiding(pattern []rune) *big.Int {
+	m := len(pattern)
+	n := l.geometry.CharsPerPage()
+	if m == 0 {
+		return big.NewInt(0)
+	}
+
+	// correlation[k] is 1 when the pattern overlaps itself shifted by k
+	correlation := make([]int64, m)
+	shifts := []int{}
+	for shift := range m {
+		correlation[shift] = 1
+		for i := shift; i < m; i++ {
+			if pattern[i] != pattern[i-shift] {
+				correlation[shift] = 0
+				break
+			}
+		}
+		if correlation[shift] == 1 && shift > 0 {
+			shifts = append(shifts, shift)
+		}
+	}
+	runs := overlapRuns(shifts)
+
+	// sums[d][t] is e_t + e_(t-d) + e_(t-2d) + ..., for each difference d of the runs
+	sums := map[int][]*big.Int{}
+	for _, run := range runs {
+		sums[run.step] = make([]*big.Int, n+1)
+	}
+	sumAt := func(step, t int) *big.Int {
+		if t < 0 {
+			return new(big.Int)
+		}
+		return sums[step][t]
+	}
+
+	avoiding := make([]*big.Int, n+1)
+	previous := big.NewInt(0)
+	for t := 0; t <= n; t++ {
+		e := big.NewInt(0)
+		if t < m {
+			e.SetInt64(correlation[t])
+		}
+		if t >= m {
+			e.Sub(e, avoiding[t-m])
+		}
+		for _, run := range runs {
+			e.Sub(e, sumAt(run.step, t-run.first))
+			e.Add(e, sumAt(run.step, t-run.last-run.step))
+		}
+		for step, sum := range sums {
+			sum[t] = new(big.Int).Add(e, sumAt(step, t-step))
+		}
+		a := new(big.Int).Mul(previous, l.base)
+		avoiding[t] = a.Add(a, e)
+		previous = avoiding[t]
+	}
+	return avoiding[n]
+}
+
+// overlapRun is the shifts first, first+step, ..., last
+type overlapRun struct {
+	first int
+	last  int
+	step  int
+}
+
+// overlapRuns splits increasing shifts into runs of evenly spaced ones, extending each
+// run as long as the spacing holds
+func overlapRuns(shifts []int) []overlapRun {
+	runs := []overlapRun{}
+	for i := 0; i < len(shifts); {
+		run := overlapRun{first: shifts[i], last: shifts[i], step: 1}
+		if i+1 < len(shifts) {
+			run.step = shifts[i+1] - shifts[i]
+		}
+		i++
+		for i < len(shifts) && shifts[i]-run.last == run.step {
+			run.last = shifts[i]
+			i++
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// FormatScientific writes n in scientific notation with three significant digits, e.g. 1.23e4567
+func FormatScientific(n *big.Int) string {
+	digits := new(big.Int).Abs(n).Text(10)
+	sign := ""
+	if n.Sign() < 0 {
+		sign = "-"
+	}
+	if len(digits) <= 3 {
+		return sign + digits
+	}
+
+	mantissa, _ := strconv.ParseFloat(digits[:1]+"."+digits[1:min(len(digits), 16)], 64)
+	exponent := len(digits) - 1
+	formatted := strconv.FormatFloat(mantissa, 'f', 2, 64)
+	// rounding can carry the mantissa up to 10
+	if formatted == "10.00" {
+		formatted = "1.00"
+		exponent++
+	}
+	return fmt.Sprintf("%s%se%d", sign, formatted, exponent)
+}
+
+// FormatPower writes a positive n as a power of base with two decimals, e.g. 29^3189.52
+func FormatPower(n *big.Int, base int) string {
+	if n.Sign() <= 0 || base < 2 {
+		return "0"
+	}
+	digits := n.Text(10)
+	mantissa, _ := strconv.ParseFloat(digits[:1]+"."+digits[1:min(len(digits), 16)], 64)
+	log10 := float64(len(digits)-1) + math.Log10(mantissa)
+	return fmt.Sprintf("%d^%.2f", base, log10/math.Log10(float64(base)))
+}
@@ -0,0 +1,107 @@
+package library
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING exact occurrence counts
+*/
+
+func TestGetOccurrenceCountMatchesBruteForce(t *testing.T) {
+	charset, err := NewCharset("abc", "abc")
+	if err != nil {
+		t.Fatalf("failed to build charset: %v", err)
+	}
+	geometry := DefaultGeometry
+	geometry.LinesPerPage, geometry.CharsPerLine = 1, 7
+	library := newTestLibrary(t, WithCharset(charset), WithGeometry(geometry))
+
+	// every page of the library, 3^7 of them
+	pages := []string{}
+	for i := range library.PageCount().Int64() {
+		pages = append(pages, library.base29NumberToString(big.NewInt(i)))
+	}
+
+	// aperiodic, periodic and self overlapping patterns
+	patterns := []string{
+		"a", "ab", "aa", "aaa", "aba", "abab", "abca", "cabbac", "aaaaaaa",
+		"aabaa", "abaaba", "aabaaba", "abaabab", "aaabaaa",
+	}
+	for _, pattern := range patterns {
+		expected := int64(0)
+		for _, page := range pages {
+			if strings.Contains(page, pattern) {
+				expected++
+			}
+		}
+		if got := library.GetOccurrenceCount(pattern); got.Int64() != expected {
+			t.Errorf("%q: got %s pages, want %d", pattern, got, expected)
+		}
+	}
+}
+
+func TestGetOccurrenceCountImpossibleText(t *testing.T) {
+	library := newTestLibrary(t)
+	if got := library.GetOccurrenceCount("hello!"); got.Sign() != 0 {
+		t.Errorf("expected no pages for invalid characters, got %s", got)
+	}
+	if got := library.GetOccurrenceCount(strings.Repeat("a", 3201)); got.Sign() != 0 {
+		t.Errorf("expected no pages for text longer than a page, got %s", got)
+	}
+	if got := library.GetOccurrenceCount(strings.Repeat("a", 3200)); got.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("expected exactly one page for a full page of text, got %s", got)
+	}
+}
+
+func TestGetOccurrenceCountIgnoresCase(t *testing.T) {
+	library := newTestLibrary(t)
+	if library.GetOccurrenceCount("Hello").Cmp(library.GetOccurrenceCount("hello")) != 0 {
+		t.Errorf("expected case folded texts to share a count")
+	}
+}
+
+func TestReachableCount(t *testing.T) {
+	library := newTestLibrary(t)
+	if got := library.ReachableCount(searchText); got != maxReachableVariants {
+		t.Errorf("got %d, want %d", got, maxReachableVariants)
+	}
+
+	text := strings.Repeat("a", 3199)
+	// 29 choices for the free character at either end, less the page of 3200 a's counted twice
+	if got := library.ReachableCount(text); got != 57 {
+		t.Errorf("got %d, want 57", got)
+	}
+}
+
+/*
+	TESTING magnitude formatting
+*/
+
+func TestFormatScientific(t *testing.T) {
+	tests := map[string]*big.Int{
+		"0":       big.NewInt(0),
+		"999":     big.NewInt(999),
+		"1.00e3":  big.NewInt(1000),
+		"1.23e6":  big.NewInt(1_234_567),
+		"1.00e4":  big.NewInt(9_999),
+		"-4.57e5": big.NewInt(-456_789),
+	}
+	for want, n := range tests {
+		if got := FormatScientific(n); got != want {
+			t.Errorf("%s: got %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestFormatPower(t *testing.T) {
+	n := new(big.Int).Exp(big.NewInt(29), big.NewInt(3189), nil)
+	if got := FormatPower(n, 29); got != "29^3189.00" {
+		t.Errorf("got %s, want 29^3189.00", got)
+	}
+	if got := FormatPower(big.NewInt(0), 29); got != "0" {
+		t.Errorf("got %s, want 0", got)
+	}
+}
@@ -0,0 +1,129 @@
+package library
+
+import (
+	_ "embed"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Fill is how a search writes the rest of a page around the searched text
+type Fill string
+
+const (
+	// FillRandom writes uniformly random charset symbols, the default
+	FillRandom Fill = "random"
+	// FillBlank writes the charset's first symbol, a space in the preset charsets,
+	// so the page holds nothing but the text
+	FillBlank Fill = "blank"
+	// FillEnglish writes common English words drawn at random, with the odd comma and period
+	FillEnglish Fill = "english"
+)
+
+//go:embed words.txt
+var wordList string
+
+// WithFill selects how the rest of the page is written, FillRandom by default
+func WithFill(fill Fill) SearchOption {
+	return func(c *searchConfig) {
+		c.fill = fill
+	}
+}
+
+func (f Fill) validate() error {
+	switch f {
+	case FillRandom, FillBlank, FillEnglish:
+		return nil
+	default:
+		return fmt.Errorf("unknown fill %q, expected %s, %s or %s", f, FillRandom, FillBlank, FillEnglish)
+	}
+}
+
+// fillerWords lists the words of the embedded word list that charset can spell.
+// Words are separated by spaces, so a charset without one spells none of them.
+func fillerWords(charset *Charset) []string {
+	if !charset.Contains(' ') {
+		return nil
+	}
+	words := []string{}
+	for word := range strings.FieldsSeq(wordList) {
+		if spellable := strings.IndexFunc(word, func(char rune) bool {
+			return !charset.Contains(char)
+		}) < 0; spellable {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// segment is a run of text written at an offset of a page
+type segment struct {
+	offset int
+	text   []rune
+}
+
+// writePage writes segments, ordered by offset and apart from one another, on a page
+// filled in the fill's style
+func (l Library) writePage(fill Fill, rng *rand.Rand, segments []segment) []rune {
+	charsPerPage := l.geometry.CharsPerPage()
+	symbols := l.charset.symbols
+	pageChars := make([]rune, charsPerPage)
+
+	switch fill {
+	case FillBlank:
+		for i := range pageChars {
+			pageChars[i] = symbols[0]
+		}
+	case FillEnglish:
+		// whole words between the segments, kept apart from them by a space
+		start := 0
+		for _, segment := range segments {
+			copy(pageChars[start:], l.englishFiller(rng, max(0, segment.offset-start)))
+			start = segment.offset + len(segment.text)
+			if start < charsPerPage {
+				pageChars[start] = ' '
+				start++
+			}
+		}
+		copy(pageChars[start:], l.englishFiller(rng, charsPerPage-start))
+	default:
+		for i := range pageChars {
+			pageChars[i] = symbols[rng.Intn(len(symbols))]
+		}
+	}
+
+	for _, segment := range segments {
+		copy(pageChars[segment.offset:], segment.text)
+	}
+	return pageChars
+}
+
+// englishFiller writes length characters of words each followed by a space, padding
+// with spaces once the next word no longer fits so no word is cut short
+func (l Library) englishFiller(rng *rand.Rand, length int) []rune {
+	filler := make([]rune, 0, length)
+	for {
+		word := []rune(l.words[rng.Intn(len(l.words))])
+		switch rng.Intn(16) {
+		case 0:
+			word = l.appendIfSpellable(word, '.')
+		case 1:
+			word = l.appendIfSpellable(word, ',')
+		}
+		if len(filler)+len(word)+1 > length {
+			break
+		}
+		filler = append(append(filler, word...), ' ')
+	}
+	for len(filler) < length {
+		filler = append(filler, ' ')
+	}
+	return filler
+}
+
+func (l Library) appendIfSpellable(word []rune, char rune) []rune {
+	if l.charset.Contains(char) {
+		return append(word, char)
+	}
+	return word
+}
@@ -0,0 +1,94 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING search fill modes
+*/
+
+func TestSearchWithBlankFill(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated(searchText, 0, 5, WithFill(FillBlank))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+
+	for i, result := range results {
+		// variants walk through the positions in order
+		if result.Offset != i {
+			t.Errorf("variant %d: got offset %d, want %d", i, result.Offset, i)
+		}
+		page, _ := library.Browse(result.Location)
+		if strings.TrimSpace(page) != searchText {
+			t.Errorf("expected a page holding nothing but the text, got %q", strings.TrimSpace(page))
+		}
+	}
+}
+
+func TestReachableCountWithBlankFill(t *testing.T) {
+	library := newTestLibrary(t)
+	tests := []struct {
+		text string
+		opts []SearchOption
+		want int
+	}{
+		{searchText, nil, 3200 - 11 + 1},
+		{searchText, []SearchOption{WithPosition(2, 1)}, 1},
+		{searchText, []SearchOption{WithLineStart()}, 40},
+		{"   ", nil, 1},
+	}
+	for _, test := range tests {
+		opts := append(test.opts, WithFill(FillBlank))
+		if got := library.ReachableCount(test.text, opts...); got != test.want {
+			t.Errorf("%q: got %d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSearchWithEnglishFill(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated(searchText, 0, 5, WithFill(FillEnglish))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+
+	words := map[string]bool{}
+	for _, word := range library.words {
+		words[word] = true
+	}
+	for _, result := range results {
+		page, _ := library.Browse(result.Location)
+		end := result.Offset + result.Length
+		filler := page[:result.Offset] + page[end:]
+		for _, field := range strings.Fields(strings.NewReplacer(",", "", ".", "").Replace(filler)) {
+			if !words[field] {
+				t.Errorf("unexpected filler word %q", field)
+			}
+		}
+		if result.Offset > 0 && page[result.Offset-1] != ' ' {
+			t.Errorf("expected a space before the text, got %q", page[result.Offset-1])
+		}
+		if end < len(page) && page[end] != ' ' {
+			t.Errorf("expected a space after the text, got %q", page[end])
+		}
+	}
+}
+
+func TestSearchWithEnglishFillNeedsSpellableCharset(t *testing.T) {
+	library := newTestLibrary(t, WithCharset(CharsetGreek))
+	if _, err := library.SearchPaginated("αβγ", 0, 1, WithFill(FillEnglish)); err == nil {
+		t.Errorf("got nil, expected err for a charset that cannot spell English")
+	}
+	if _, err := library.SearchPaginated("αβγ", 0, 1, WithFill("prose")); err == nil {
+		t.Errorf("got nil, expected err for an unknown fill")
+	}
+}
@@ -0,0 +1,67 @@
+package library
+
+import "fmt"
+
+// Geometry describes how the library is laid out, from the walls of a hexagon
+// down to the characters on a line of a page
+type Geometry struct {
+	WallsPerHexagon int
+	ShelvesPerWall  int
+	BooksPerShelf   int
+	PagesPerBook    int
+	LinesPerPage    int
+	CharsPerLine    int
+}
+
+// DefaultGeometry is the layout described by Borges: 4 walls of 5 shelves, each holding
+// 32 books of 410 pages, with 40 lines of 80 characters per page
+var DefaultGeometry = Geometry{
+	WallsPerHexagon: 4,
+	ShelvesPerWall:  5,
+	BooksPerShelf:   32,
+	PagesPerBook:    410,
+	LinesPerPage:    40,
+	CharsPerLine:    80,
+}
+
+// WithGeometry sets the layout of the library's hexagons, books and pages
+func WithGeometry(geometry Geometry) Option {
+	return func(l *Library) error {
+		if err := geometry.Validate(); err != nil {
+			return err
+		}
+		l.geometry = geometry
+		return nil
+	}
+}
+
+// Validate checks that every dimension of the geometry is positive
+func (g Geometry) Validate() error {
+	dimensions := []struct {
+		name  string
+		value int
+	}{
+		{"walls per hexagon", g.WallsPerHexagon},
+		{"shelves per wall", g.ShelvesPerWall},
+		{"books per shelf", g.BooksPerShelf},
+		{"pages per book", g.PagesPerBook},
+		{"lines per page", g.LinesPerPage},
+		{"characters per line", g.CharsPerLine},
+	}
+	for _, dimension := range dimensions {
+		if dimension.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", dimension.name, dimension.value)
+		}
+	}
+	return nil
+}
+
+// CharsPerPage is the number of characters written on a single page
+func (g Geometry) CharsPerPage() int {
+	return g.LinesPerPage * g.CharsPerLine
+}
+
+// PagesPerHexagon is the number of pages held by the books on all walls of a hexagon
+func (g Geometry) PagesPerHexagon() int {
+	return g.WallsPerHexagon * g.ShelvesPerWall * g.BooksPerShelf * g.PagesPerBook
+}
@@ -0,0 +1,124 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+var smallGeometry = Geometry{
+	WallsPerHexagon: 2,
+	ShelvesPerWall:  8,
+	BooksPerShelf:   4,
+	PagesPerBook:    10,
+	LinesPerPage:    40,
+	CharsPerLine:    40,
+}
+
+/*
+	TESTING Geometry validation
+*/
+
+func TestGeometryValidate(t *testing.T) {
+	if err := DefaultGeometry.Validate(); err != nil {
+		t.Errorf("default geometry should be valid: %v", err)
+	}
+
+	invalid := DefaultGeometry
+	invalid.ShelvesPerWall = 0
+	if err := invalid.Validate(); err == nil {
+		t.Errorf("got nil, expected err for zero shelves per wall")
+	}
+
+	if _, err := NewLibrary(WithGeometry(Geometry{})); err == nil {
+		t.Errorf("got nil, expected err for empty geometry")
+	}
+}
+
+func TestGeometryDerivedSizes(t *testing.T) {
+	if got := DefaultGeometry.CharsPerPage(); got != 3200 {
+		t.Errorf("expected 3200 characters per page, got %d", got)
+	}
+	if got := DefaultGeometry.PagesPerHexagon(); got != 4*5*32*410 {
+		t.Errorf("expected %d pages per hexagon, got %d", 4*5*32*410, got)
+	}
+}
+
+/*
+	TESTING a library laid out in a custom geometry
+*/
+
+func TestLibraryCustomGeometryRoundTrip(t *testing.T) {
+	library := newTestLibrary(t, WithGeometry(smallGeometry))
+
+	results, err := library.SearchPaginated(searchText, 0, 10)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		location := result.Location
+		if location.Geometry() != smallGeometry {
+			t.Errorf("location %s not laid out in the library geometry", location)
+		}
+
+		parsed, err := library.LocationFromString(location.String())
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", location, err)
+			continue
+		}
+		page, err := library.Browse(parsed)
+		if err != nil {
+			t.Errorf("failed to browse %s: %v", location, err)
+			continue
+		}
+		if length := len(page); length != smallGeometry.CharsPerPage() {
+			t.Errorf("expected %d characters, got %d", smallGeometry.CharsPerPage(), length)
+		}
+		if !strings.Contains(page, searchText) {
+			t.Errorf("page at %s does not contain %q", location, searchText)
+		}
+		if line, column := result.Line, result.Column; line > smallGeometry.LinesPerPage ||
+			column > smallGeometry.CharsPerLine {
+			t.Errorf("match at line %d, column %d is off the page", line, column)
+		}
+	}
+}
+
+func TestLibraryCustomGeometryLocationBounds(t *testing.T) {
+	library := newTestLibrary(t, WithGeometry(smallGeometry))
+
+	if _, err := library.LocationFromString("abc.1.7.3.10"); err != nil {
+		t.Errorf("expected valid address, got err: %v", err)
+	}
+	// shelf 7 is valid in smallGeometry but the default geometry only has 5 shelves
+	if _, err := LocationFromString("abc.1.7.3.10"); err == nil {
+		t.Errorf("got nil, expected err for shelf outside the default geometry")
+	}
+	// page 11 is past the end of a 10 page book
+	if _, err := library.LocationFromString("abc.1.7.3.11"); err == nil {
+		t.Errorf("got nil, expected err for page outside the library geometry")
+	}
+}
+
+func TestLocationNextWrapsInCustomGeometry(t *testing.T) {
+	library := newTestLibrary(t, WithGeometry(smallGeometry))
+	location, err := library.LocationFromString("0.1.7.3.10")
+	if err != nil {
+		t.Fatalf("failed to parse address: %v", err)
+	}
+
+	next := location.Next()
+	if got := next.String(); got != "1.0.0.0.1" {
+		t.Errorf("got %s, want 1.0.0.0.1", got)
+	}
+	if prev := next.Previous(); !prev.Equals(*location) {
+		t.Errorf("got %s, want %s", prev, location)
+	}
+
+	number, err := next.ToBigInt()
+	if err != nil {
+		t.Fatalf("failed to convert location: %v", err)
+	}
+	if expected := int64(smallGeometry.PagesPerHexagon()); number.Int64() != expected {
+		t.Errorf("got %d, want %d", number.Int64(), expected)
+	}
+}
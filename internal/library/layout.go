@@ -0,0 +1,239 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Layout is the shape a search writes its text in
+type Layout string
+
+const (
+	// LayoutRun writes the text as a single run wrapping from one line to the next, the default
+	LayoutRun Layout = "run"
+	// LayoutVertical writes the text down a column
+	LayoutVertical Layout = "vertical"
+	// LayoutDiagonal writes the text down and to the right, a character per line
+	LayoutDiagonal Layout = "diagonal"
+	// LayoutBlock writes each line of the text below the last, starting at the same column,
+	// keeping ASCII art in shape
+	LayoutBlock Layout = "block"
+)
+
+// WithLayout selects the shape the text is written in, LayoutRun by default.
+// Position options place the shape's top left corner, and run wildcards only apply to
+// LayoutRun.
+func WithLayout(layout Layout) SearchOption {
+	return func(c *searchConfig) {
+		c.layout = layout
+	}
+}
+
+func (layout Layout) validate() error {
+	switch layout {
+	case LayoutRun, LayoutVertical, LayoutDiagonal, LayoutBlock:
+		return nil
+	default:
+		return fmt.Errorf("unknown layout %q, expected %s, %s, %s or %s",
+			layout, LayoutRun, LayoutVertical, LayoutDiagonal, LayoutBlock)
+	}
+}
+
+// placement is text as written on a page, each character at its own page offset
+type placement struct {
+	text  []rune
+	cells []int
+	// which characters were written literally rather than filled in for a wildcard,
+	// nil when all of them were
+	literal []bool
+}
+
+// segments groups the characters of the placement into runs of neighbouring cells
+func (p placement) segments() []segment {
+	segments := []segment{}
+	for i, cell := range p.cells {
+		if last := len(segments) - 1; last >= 0 &&
+			segments[last].offset+len(segments[last].text) == cell {
+			segments[last].text = append(segments[last].text, p.text[i])
+			continue
+		}
+		segments = append(segments, segment{offset: cell, text: []rune{p.text[i]}})
+	}
+	return segments
+}
+
+// shape is text laid out line by line, each character at a line and column counted from
+// the shape's top left corner
+type shape struct {
+	text    []rune
+	literal []bool
+	lines   []int
+	columns []int
+	height  int
+	width   int
+}
+
+// shape lays text out in the layout. Line breaks in block text move to the next line and
+// take no cell of their own.
+func (layout Layout) shape(text []rune, literal []bool) shape {
+	s := shape{}
+	line, column := 0, 0
+	for i, char := range text {
+		if layout == LayoutBlock && char == '\n' {
+			line++
+			column = 0
+			continue
+		}
+		s.text = append(s.text, char)
+		if literal != nil {
+			s.literal = append(s.literal, literal[i])
+		}
+		s.lines = append(s.lines, line)
+		s.columns = append(s.columns, column)
+		s.height = max(s.height, line+1)
+		s.width = max(s.width, column+1)
+
+		switch layout {
+		case LayoutVertical:
+			line++
+		case LayoutDiagonal:
+			line++
+			column++
+		default:
+			column++
+		}
+	}
+	return s
+}
+
+// validateShape checks the shape fits on a page at the position
+func (p position) validateShape(s shape, geometry Geometry) error {
+	if len(s.text) == 0 {
+		return errors.New("text should not be empty")
+	}
+	if s.height > geometry.LinesPerPage || s.width > geometry.CharsPerLine {
+		return fmt.Errorf("text %d lines high and %d characters wide does not fit on a page",
+			s.height, s.width)
+	}
+	if p.kind != positionExact {
+		return nil
+	}
+	if err := p.validate(1, geometry); err != nil {
+		return err
+	}
+	if p.line-1+s.height > geometry.LinesPerPage || p.column-1+s.width > geometry.CharsPerLine {
+		return fmt.Errorf("text %d lines high and %d characters wide runs off the page from line %d, column %d",
+			s.height, s.width, p.line, p.column)
+	}
+	return nil
+}
+
+// shapeCandidates is the number of places the shape's top left corner can take
+func (p position) shapeCandidates(s shape, geometry Geometry) int {
+	lines := geometry.LinesPerPage - s.height + 1
+	switch p.kind {
+	case positionExact:
+		return 1
+	case positionLineStart, positionCentred:
+		return lines
+	default:
+		return lines * (geometry.CharsPerLine - s.width + 1)
+	}
+}
+
+// shapeCorner is the line and column, counting from 0, of the candidate'th place the
+// shape's top left corner can take
+func (p position) shapeCorner(candidate int, s shape, geometry Geometry) (int, int) {
+	switch p.kind {
+	case positionExact:
+		return p.line - 1, p.column - 1
+	case positionLineStart:
+		return candidate, 0
+	case positionCentred:
+		return candidate, (geometry.CharsPerLine - s.width) / 2
+	default:
+		columns := geometry.CharsPerLine - s.width + 1
+		return candidate / columns, candidate % columns
+	}
+}
+
+// placeShape writes the shape with its top left corner at the candidate'th place
+func (p position) placeShape(candidate int, s shape, geometry Geometry) placement {
+	line, column := p.shapeCorner(candidate, s, geometry)
+	cells := make([]int, len(s.text))
+	for i := range s.text {
+		cells[i] = (line+s.lines[i])*geometry.CharsPerLine + column + s.columns[i]
+	}
+	return placement{text: s.text, cells: cells, literal: s.literal}
+}
+
+// place lays text out as config describes, below the first top characters of the page,
+// drawing where it sits from rng or, on blank pages, walking through the places by variant
+func (l Library) place(text []rune, literal []bool, variant, top int, config searchConfig, rng *rand.Rand) placement {
+	if config.layout != LayoutRun {
+		s := config.layout.shape(text, literal)
+		candidates := config.position.shapeCandidates(s, l.geometry)
+		candidate := variant % candidates
+		if config.fill != FillBlank && config.position.kind != positionExact {
+			candidate = rng.Intn(candidates)
+		}
+		return config.position.placeShape(candidate, s, l.geometry)
+	}
+	if config.shortest {
+		return runPlacement(text, literal, l.geometry.CharsPerPage()-len(text)-l.shelvingWidth(config))
+	}
+	if config.wordWrap {
+		starts := config.wrapping.starts
+		start := starts[variant%len(starts)]
+		if config.fill != FillBlank {
+			start = starts[rng.Intn(len(starts))]
+		}
+		text, _ = wrap(text, start%l.geometry.CharsPerLine, l.geometry.CharsPerLine)
+		return runPlacement(text, nil, start)
+	}
+
+	if top > 0 {
+		candidates := l.vanityCandidates(len(text), top, config.position)
+		candidate := candidates[variant%len(candidates)]
+		if config.fill != FillBlank {
+			candidate = candidates[rng.Intn(len(candidates))]
+		}
+		return runPlacement(text, literal, config.position.offset(candidate, len(text), l.geometry))
+	}
+
+	var offset int
+	if config.fill == FillBlank {
+		// blank pages only differ in where the text sits, so variants walk through the positions
+		candidates := config.position.candidates(len(text), l.geometry)
+		offset = config.position.offset(variant%candidates, len(text), l.geometry)
+	} else {
+		offset = config.position.pick(rng, len(text), l.geometry)
+	}
+	return runPlacement(text, literal, offset)
+}
+
+// runPlacement writes text as a single run from offset
+func runPlacement(text []rune, literal []bool, offset int) placement {
+	cells := make([]int, len(text))
+	for i := range text {
+		cells[i] = offset + i
+	}
+	return placement{text: text, cells: cells, literal: literal}
+}
+
+// validateLayout checks text can be written in config's layout
+func (l Library) validateLayout(text string, config *searchConfig) error {
+	if config.wordWrap {
+		return l.validateWrap(text, config)
+	}
+	if config.layout == LayoutRun {
+		return config.position.validate(config.textLength(text), l.geometry)
+	}
+	if config.wildcards && strings.ContainsRune(text, wildcardRun) {
+		return fmt.Errorf("run wildcards do not apply to the %s layout", config.layout)
+	}
+	s := config.layout.shape([]rune(text), nil)
+	return config.position.validateShape(s, l.geometry)
+}
@@ -0,0 +1,126 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING vertical, diagonal and block layouts
+*/
+
+func TestSearchWithLayout(t *testing.T) {
+	library := newTestLibrary(t)
+	cpl := DefaultGeometry.CharsPerLine
+	tests := map[Layout]struct {
+		text string
+		// distance between the cells of neighbouring characters
+		step int
+	}{
+		LayoutVertical: {searchText, cpl},
+		LayoutDiagonal: {searchText, cpl + 1},
+	}
+	for layout, test := range tests {
+		results, err := library.SearchPaginated(test.text, 0, 10, WithLayout(layout))
+		if err != nil {
+			t.Fatalf("%s: search failed: %v", layout, err)
+		}
+		for _, result := range results {
+			page, err := library.Browse(result.Location)
+			if err != nil {
+				t.Fatalf("%s: failed to browse: %v", layout, err)
+			}
+			pageChars := []rune(page)
+			if len(result.Cells) != len(test.text) {
+				t.Fatalf("%s: got %d cells, want %d", layout, len(result.Cells), len(test.text))
+			}
+			for i, cell := range result.Cells {
+				if pageChars[cell] != rune(test.text[i]) {
+					t.Errorf("%s: cell %d holds %q, want %q", layout, cell, pageChars[cell], test.text[i])
+				}
+				if i > 0 && cell-result.Cells[i-1] != test.step {
+					t.Errorf("%s: cells %d apart, want %d", layout, cell-result.Cells[i-1], test.step)
+				}
+			}
+			if result.Offset != result.Cells[0] || result.Snippet.Match != test.text {
+				t.Errorf("%s: match should start at the first cell and hold the text", layout)
+			}
+		}
+	}
+}
+
+func TestSearchWithBlockLayout(t *testing.T) {
+	library := newTestLibrary(t)
+	art := " ,.,\nc o d\n  v"
+	rows := strings.Split(art, "\n")
+
+	results, err := library.SearchPaginated(art, 0, 5, WithLayout(LayoutBlock), WithPosition(10, 20))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	cpl := DefaultGeometry.CharsPerLine
+	for _, result := range results {
+		page, err := library.Browse(result.Location)
+		if err != nil {
+			t.Fatalf("failed to browse: %v", err)
+		}
+		for i, row := range rows {
+			start := (9+i)*cpl + 19
+			if got := page[start : start+len(row)]; got != row {
+				t.Errorf("line %d: got %q, want %q", 10+i, got, row)
+			}
+		}
+		if result.Line != 10 || result.Column != 20 {
+			t.Errorf("got line %d, column %d, want line 10, column 20", result.Line, result.Column)
+		}
+	}
+}
+
+func TestSearchWithLayoutWalksBlankPages(t *testing.T) {
+	library := newTestLibrary(t)
+	count := library.ReachableCount("abc", WithLayout(LayoutVertical), WithFill(FillBlank))
+	// 38 lines by 80 columns for the top character
+	if count != 38*80 {
+		t.Fatalf("got %d reachable pages, want %d", count, 38*80)
+	}
+	results, err := library.SearchPaginated("abc", 0, count+10, WithLayout(LayoutVertical), WithFill(FillBlank))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, result := range results {
+		seen[result.Location.String()] = true
+	}
+	if len(seen) != count {
+		t.Errorf("got %d distinct pages, want %d", len(seen), count)
+	}
+}
+
+func TestSearchWithInvalidLayout(t *testing.T) {
+	library := newTestLibrary(t)
+	tests := map[string][]SearchOption{
+		"unknown layout":       {WithLayout("spiral")},
+		"too tall":             {WithLayout(LayoutVertical)},
+		"runs off the page":    {WithLayout(LayoutDiagonal), WithPosition(35, 1)},
+		"run wildcard":         {WithLayout(LayoutVertical), WithWildcards()},
+		"excluded in a column": {WithLayout(LayoutVertical), WithExcluded("o")},
+	}
+	texts := map[string]string{
+		"too tall":     strings.Repeat("a", 41),
+		"run wildcard": "he*lo",
+	}
+	for name, opts := range tests {
+		text := searchText
+		if long, ok := texts[name]; ok {
+			text = long
+		}
+		if _, err := library.SearchPaginated(text, 0, 1, opts...); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+
+	// a column never spells text across its characters
+	if _, err := library.SearchPaginated(searchText, 0, 1, WithLayout(LayoutVertical), WithExcluded("he")); err != nil {
+		t.Errorf("got %v, expected excluded text split over lines to be allowed", err)
+	}
+}
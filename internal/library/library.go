@@ -1,51 +1,170 @@
 package library
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"math"
 	"math/big"
 	"math/rand"
-	"runtime"
-	"slices"
-	"strings"
-	"sync"
-)
-
-const (
-	pagesPerBook    = 410
-	booksPerShelf   = 32
-	shelvesPerWall  = 5
-	wallsPerHexagon = 4
-	linesPerPage    = 40
-	charsPerLine    = 80
-	charsPerPage    = linesPerPage * charsPerLine // 3200
-	// the rate at which search results in the library continue to reduce as the length of the search text increases
-	exponentialDecayRate = 1.10
+	"unicode/utf8"
 )
 
 type Library struct {
-	charset     string
-	charToIndex map[rune]int
-	base        *big.Int
+	charset  *Charset
+	geometry Geometry
+	base     *big.Int
+	// number of distinct pages, base^charsPerPage
+	pageCount *big.Int
+	// highest hexagon holding at least one page
+	maxHexagon *big.Int
+	// optional permutation between page contents and page numbers
+	scrambleKey []byte
+	scrambler   *scrambler
+	// words of the embedded word list the charset can spell, for FillEnglish
+	words []string
 }
 
-// Build the Library
-func NewLibrary() *Library {
-	charset := " abcdefghijklmnopqrstuvwxyz,."
-	charToIndex := map[rune]int{}
+// the library every package level function works against
+var defaultLibrary = mustLibrary()
 
-	for i, char := range charset {
-		charToIndex[char] = i
+// Option configures a Library built with NewLibrary
+type Option func(*Library) error
+
+// WithCharset sets the symbols the library's pages are written with
+func WithCharset(charset *Charset) Option {
+	return func(l *Library) error {
+		if charset == nil {
+			return errors.New("charset should not be nil")
+		}
+		l.charset = charset
+		return nil
 	}
+}
 
-	return &Library{
-		charset:     charset,
-		charToIndex: charToIndex,
-		base:        big.NewInt(29),
+// Build the Library, by default with the 29 character CharsetDefault laid out in DefaultGeometry
+func NewLibrary(opts ...Option) (*Library, error) {
+	library := &Library{charset: CharsetDefault, geometry: DefaultGeometry}
+	for _, opt := range opts {
+		if err := opt(library); err != nil {
+			return nil, err
+		}
+	}
+	library.base = big.NewInt(int64(library.charset.Size()))
+	library.pageCount = pageCount(library.charset, library.geometry)
+	library.maxHexagon = maxHexagon(library.pageCount, library.geometry)
+	if library.scrambleKey != nil {
+		library.scrambler = newScrambler(library.scrambleKey, library.pageCount)
 	}
+	library.words = fillerWords(library.charset)
+	return library, nil
+}
+
+// pageCount is the number of pages of a library written in charset and laid out in
+// geometry, base^charsPerPage
+func pageCount(charset *Charset, geometry Geometry) *big.Int {
+	base := big.NewInt(int64(charset.Size()))
+	return new(big.Int).Exp(base, big.NewInt(int64(geometry.CharsPerPage())), nil)
+}
+
+// maxHexagon is the highest hexagon holding one of pageCount pages laid out in geometry
+func maxHexagon(pageCount *big.Int, geometry Geometry) *big.Int {
+	last := new(big.Int).Sub(pageCount, big.NewInt(1))
+	return last.Div(last, big.NewInt(int64(geometry.PagesPerHexagon())))
+}
+
+func mustLibrary(opts ...Option) *Library {
+	library, err := NewLibrary(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return library
+}
+
+// Charset returns the symbols the library's pages are written with
+func (l Library) Charset() *Charset {
+	return l.charset
+}
+
+// Geometry returns the layout of the library's hexagons, books and pages
+func (l Library) Geometry() Geometry {
+	return l.geometry
+}
+
+// PageCount is the number of distinct pages in the library: every arrangement of
+// the charset's symbols over a page, base^charsPerPage
+func (l Library) PageCount() *big.Int {
+	return new(big.Int).Set(l.pageCount)
+}
+
+// MaxHexagon is the highest hexagon number in the library. The last hexagon is usually
+// only partially filled, its shelves end where the library's pages run out.
+func (l Library) MaxHexagon() *big.Int {
+	return new(big.Int).Set(l.maxHexagon)
+}
+
+// LocationFromString parses a "<hexagon>.<wall>.<shelf>.<book>.<page>" address
+// laid out in the library's geometry, rejecting locations past the end of the library
+func (l Library) LocationFromString(address string) (*Location, error) {
+	location, err := locationFromString(address, l.geometry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.pageNumber(location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// RandomLocation picks a location uniformly from every page in the library
+func (l Library) RandomLocation() *Location {
+	rng := rand.New(rand.NewSource(rand.Int63())) //nolint:gosec // crypto not needed
+	n := new(big.Int).Rand(rng, l.pageCount)
+	return locationFromBase29Number(n, l.geometry)
+}
+
+// Scrambled reports whether neighbouring addresses hold unrelated pages, see WithScrambling
+func (l Library) Scrambled() bool {
+	return l.scrambler != nil
+}
+
+// locate finds the location of the page whose contents read as the given base29 number
+func (l Library) locate(content *big.Int) *Location {
+	if l.scrambler != nil {
+		content = l.scrambler.forward(content)
+	}
+	return locationFromBase29Number(content, l.geometry)
+}
+
+// pageNumber converts a location to its page number, checking it is within the library
+func (l Library) pageNumber(location *Location) (*big.Int, error) {
+	n, err := location.ToBigInt()
+	if err != nil {
+		return nil, err
+	}
+	if n.Sign() < 0 || n.Cmp(l.pageCount) >= 0 {
+		return nil, &OutOfRangeError{Location: location, MaxHexagon: l.MaxHexagon()}
+	}
+	return n, nil
+}
+
+// Advance moves n pages on from location, back when n is negative, failing with
+// ErrStartOfLibrary or ErrEndOfLibrary when that leaves the library. The location must
+// be within the library.
+func (l Library) Advance(location *Location, n *big.Int) (*Location, error) {
+	index, err := l.pageNumber(location)
+	if err != nil {
+		return nil, err
+	}
+	index.Add(index, n)
+	switch {
+	case index.Sign() < 0:
+		return nil, ErrStartOfLibrary
+	case index.Cmp(l.pageCount) >= 0:
+		return nil, ErrEndOfLibrary
+	}
+	return locationFromBase29Number(index, l.geometry), nil
 }
 
 // Deprecated: Search is deprecated. Use SearchStream or SearchPaginated instead.
@@ -54,51 +173,61 @@ func (l Library) Search(text string) (*Location, error) {
 	if err != nil {
 		return nil, err
 	}
-	location := locationFromBase29Number(bigInt)
+	location := l.locate(bigInt)
 	return location, nil
 }
 
-func (l Library) SearchStream(text string) (<-chan *Location, error) {
-	totalCount := l.GetOccurrenceCount(text)
-	// location and job worker channel
-	locationChan, workerChan := make(chan *Location), make(chan int, 100)
-	// start fixed number of workers
-	numWorkers := runtime.NumCPU()
-	var wg sync.WaitGroup
-
-	for range numWorkers {
-		wg.Go(func() {
-			// each worker processes multiple variants
-			for variant := range workerChan {
-				bigInt, err := l.generateBase29Number(text, variant)
-				if err != nil {
-					continue
-				}
-				location := locationFromBase29Number(bigInt)
-				locationChan <- location
-			}
-		})
-	}
-
-	// send jobs and close channels
-	go func() {
-		defer close(workerChan)
-		for variant := range totalCount {
-			workerChan <- variant
-		}
-	}()
+// SearchStream streams the pages containing text, in variant order, until every
+// variant has been generated, the max results option is reached or ctx is cancelled.
+// The results channel is closed when the search stops, after which the error channel yields
+// at most one error: the first variant that failed to generate, or ctx's error if it was cancelled.
+// Callers that stop reading early must cancel ctx to release the search's goroutines.
+func (l Library) SearchStream(
+	ctx context.Context,
+	text string,
+	opts ...SearchOption,
+) (<-chan *SearchResult, <-chan error) {
+	config, err := newSearchConfig(opts)
+	if err == nil {
+		err = l.validateSearch(text, &config)
+	}
+	if err != nil {
+		resultChan, errChan := make(chan *SearchResult), make(chan error, 1)
+		errChan <- err
+		close(errChan)
+		close(resultChan)
+		return resultChan, errChan
+	}
 
-	// close results when workers finish
-	go func() {
-		defer close(locationChan)
-		wg.Wait()
-	}()
+	totalCount := l.reachableCount(text, config)
+	if config.maxResults > 0 {
+		totalCount = min(totalCount, config.maxResults)
+	}
 
-	return locationChan, nil
+	return generateVariants(ctx, 0, totalCount, config.workers, func(variant int) (*SearchResult, error) {
+		return l.searchResult(text, variant, config)
+	})
 }
 
-func (l Library) SearchPaginated(text string, offset, limit int) ([]*Location, error) {
-	totalCount := l.GetOccurrenceCount(text)
+// SearchPaginated returns the pages of variants offset to offset+limit of text, the same
+// results SearchStream yields at those positions. Pages are generated on all cores.
+func (l Library) SearchPaginated(
+	text string,
+	offset, limit int,
+	opts ...SearchOption,
+) ([]*SearchResult, error) {
+	config, err := newSearchConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.validateSearch(text, &config); err != nil {
+		return nil, err
+	}
+
+	totalCount := l.reachableCount(text, config)
+	if config.maxResults > 0 {
+		totalCount = min(totalCount, config.maxResults)
+	}
 
 	// validate parameters
 	if offset < 0 {
@@ -108,74 +237,65 @@ func (l Library) SearchPaginated(text string, offset, limit int) ([]*Location, e
 		return nil, errors.New("limit must be positive")
 	}
 	if offset >= totalCount {
-		return []*Location{}, nil
+		return []*SearchResult{}, nil
 	}
 
 	// calculate actual results to return
 	endIndex := min(offset+limit, totalCount)
 	actualLimit := endIndex - offset
 
-	locations := make([]*Location, 0, actualLimit)
-
-	// generate locations from offset to endIndex
-	for variant := offset; variant < endIndex; variant++ {
-		bigInt, err := l.generateBase29Number(text, variant)
-		if err != nil {
-			return nil, fmt.Errorf("error generating location for variant %d: %w", variant, err)
-		}
-
-		location := locationFromBase29Number(bigInt)
-		locations = append(locations, location)
+	results := make([]*SearchResult, 0, actualLimit)
+
+	// generate results from offset to endIndex
+	resultChan, errChan := generateVariants(
+		context.Background(), offset, endIndex, config.workers,
+		func(variant int) (*SearchResult, error) {
+			return l.searchResult(text, variant, config)
+		},
+	)
+	for result := range resultChan {
+		results = append(results, result)
+	}
+	if err := <-errChan; err != nil {
+		return nil, err
 	}
 
-	return locations, nil
-}
-
-// Deterministically determine the occurrence rate of a given text in the library
-// using exponential decay
-func (l Library) GetOccurrenceCount(text string) int {
-	textLen := len(text)
-
-	// decay initial max count exponentially by length
-	maxCount := 1_000_000_000 // 1Billion for single characters
-	baseCount := maxCount / int(math.Pow(exponentialDecayRate, float64(textLen-1)))
-
-	baseCount = max(1, baseCount)
-
-	hash := sha256.Sum256([]byte(strings.ToLower(text)))
-	seed := int64(binary.BigEndian.Uint64(hash[:8])) //nolint:gosec
-	rng := rand.New(rand.NewSource(seed))            //nolint:gosec
-
-	variation := max(1, baseCount/4) // ±25% variation, minimum 1
-	adjustment := rng.Intn(2*variation) - variation
-
-	return max(1, baseCount+adjustment)
+	return results, nil
 }
 
 func (l Library) Browse(location *Location) (string, error) {
-	bigInt, err := location.ToBigInt()
+	bigInt, err := l.pageNumber(location)
 	if err != nil {
 		return "", err
 	}
+	if l.scrambler != nil {
+		bigInt = l.scrambler.inverse(bigInt)
+	}
 	pageContent := l.base29NumberToString(bigInt)
 	return pageContent, nil
 }
 
 // Converts a given text into a base29 number.
+// The base is the size of the library's charset, 29 for CharsetDefault.
 func (l Library) generateBase29Number(text string, variant int) (*big.Int, error) {
-	if text == "" {
-		return nil, errors.New("text should not be empty")
+	if err := l.validateText(text); err != nil {
+		return nil, err
 	}
-	if len(text) > charsPerPage {
-		return nil, errors.New("text exceeds 3200 character limit")
+
+	pageChars, _, err := l.seedPageChars(text, variant, searchConfig{layout: LayoutRun})
+	if err != nil {
+		return nil, err
 	}
+	return l.pageCharsToBase29Number(pageChars)
+}
 
+// Reads a page as a base29 number, its first character being the most significant digit
+func (l Library) pageCharsToBase29Number(pageChars []rune) (*big.Int, error) {
 	result := big.NewInt(0)
-	pageChars := l.seedPageChars(text, variant)
 
 	for _, char := range pageChars {
 		result.Mul(result, l.base)
-		index, exists := l.charToIndex[char]
+		index, exists := l.charset.index[char]
 		if !exists {
 			return nil, fmt.Errorf(
 				"text contains invalid characters, supported charset: %v", l.charset,
@@ -187,61 +307,103 @@ func (l Library) generateBase29Number(text string, variant int) (*big.Int, error
 	return result, nil
 }
 
+// Checks text can be written on a single page of the library
+func (l Library) validateText(text string) error {
+	if text == "" {
+		return errors.New("text should not be empty")
+	}
+	if charsPerPage := l.geometry.CharsPerPage(); utf8.RuneCountInString(text) > charsPerPage {
+		return fmt.Errorf("text exceeds %d character limit", charsPerPage)
+	}
+	return l.checkCharset(text, "")
+}
+
 // A deterministic seed based on the hash of the input text is used to generate the position
 // The text will appear in the page, the same seed is used to populate the page contents
-func (l Library) seedPageChars(text string, variant int) string {
-	input := fmt.Sprintf("%s\x00%d", strings.ToLower(text), variant)
+// The position is drawn from the places config allows the text to start at, and the rest
+// of the page is written in config's fill
+// Returns the page and the text as written on it, wildcards filled in
+func (l Library) seedPageChars(text string, variant int, config searchConfig) ([]rune, placement, error) {
+	text = l.charset.Fold(text)
+	input := fmt.Sprintf("%s\x00%d", text, variant)
 	textHash := sha256.Sum256([]byte(input))
 	textSeed := int64(binary.BigEndian.Uint64(textHash[:8])) //nolint:gosec // overflow acceptable
 	rng := rand.New(rand.NewSource(textSeed))                //nolint:gosec // crypto not needed
 
+	textRunes := []rune(text)
+	var literal []bool
+	if config.wildcards {
+		room := config.position.room(config.textLength(text), l.geometry)
+		textRunes, literal = l.expandPattern(rng, textRunes, room)
+	}
+
+	// pages of a vanity hexagon start with the characters spelling its prefix
+	var top []rune
+	if config.prefix != "" {
+		var err error
+		if top, err = l.hexagonPrefixChars(config.prefix); err != nil {
+			return nil, placement{}, err
+		}
+	}
+
 	// Generate position from seeded rng
-	maxPosition := charsPerPage - len(text)
-	position := rng.Intn(maxPosition + 1)
+	placed := l.place(textRunes, literal, variant, len(top), config, rng)
+
+	// insert text at determined position, short addresses reading zero everywhere else
+	fill := config.fill
+	if config.shortest {
+		fill = FillBlank
+	}
+	pageChars := l.writePage(fill, rng, placed.segments())
+	copy(pageChars, top)
 
-	pageChars := make([]byte, charsPerPage)
-	for i := range charsPerPage {
-		pageChars[i] = l.charset[rng.Intn(len(l.charset))]
+	fixed := make([]bool, len(pageChars))
+	for cell := range top {
+		fixed[cell] = true
+	}
+	for i, cell := range placed.cells {
+		fixed[cell] = placed.literal == nil || placed.literal[i]
+	}
+	if err := l.avoidExcluded(pageChars, fixed, config.excluded, rng); err != nil {
+		return nil, placement{}, err
 	}
 
-	// insert text at determined position
-	copy(pageChars[position:], []byte(strings.ToLower(text)))
+	// move the page onto the chosen wall, shelf, book and page without touching the text
+	occupied := make([]bool, len(pageChars))
+	for cell := range top {
+		occupied[cell] = true
+	}
+	for _, cell := range placed.cells {
+		occupied[cell] = true
+	}
+	if config.shortest {
+		if err := l.writeShortVariant(pageChars, occupied, variant); err != nil {
+			return nil, placement{}, err
+		}
+	}
+	if err := l.shelveAvoiding(pageChars, occupied, config, rng); err != nil {
+		return nil, placement{}, err
+	}
 
-	return string(pageChars)
+	return pageChars, placed, nil
 }
 
-// Convert base29 number back to a string
+// Convert base29 number back to a string.
+// Numbers are always below the library's page count, so they never need more than a page
+// of digits, and short numbers are left padded with the charset's zero symbol. This makes
+// the conversion the exact inverse of pageCharsToBase29Number.
 func (l Library) base29NumberToString(n *big.Int) string {
+	charsPerPage := l.geometry.CharsPerPage()
 	temp := new(big.Int).Abs(n)
 	quotient, remainder := new(big.Int), new(big.Int)
-	runes := []rune{}
+	symbols := l.charset.symbols
+	runes := make([]rune, charsPerPage)
 
-	for temp.Sign() > 0 {
+	for i := charsPerPage - 1; i >= 0; i-- {
 		quotient, remainder = quotient.DivMod(temp, l.base, remainder)
-		charByte := l.charset[remainder.Int64()]
-		runes = append(runes, rune(charByte))
+		runes[i] = symbols[remainder.Int64()]
 		temp.Set(quotient)
 	}
 
-	slices.Reverse(runes)
-
-	// XXX: Hack to ensure page contains 3200 characters
-	// if we are unable to fill a page with 3200 chars, use existing chars
-	// as seed to generate random chars to fill the page
-	if len(runes) < charsPerPage {
-		startIdx := len(runes)
-		hash := sha256.Sum256([]byte(string(runes)))
-		seed := int64(binary.BigEndian.Uint64(hash[:8])) //nolint:gosec
-		rng := rand.New(rand.NewSource(seed))            //nolint:gosec // crypto not needed
-		for i := startIdx; i < charsPerPage; i++ {
-			byte := l.charset[rng.Intn(len(l.charset))]
-			runes = append(runes, rune(byte))
-		}
-	}
-
-	// TODO: Handle len(runes) > charsPerPage -> this will happen if a user provides
-	//       a hexagon value with characters > 3004 when calling the Browse API, which
-	//       will produce a page with more than the expected 3200 characters.
-
 	return string(runes)
 }
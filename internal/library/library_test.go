@@ -1,9 +1,14 @@
 package library
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 const searchText = "hello world"
@@ -13,27 +18,87 @@ TESTING core library of babel API
 */
 
 func TestLibrarySearchStream(t *testing.T) {
-	library := NewLibrary()
-	results, err := library.SearchStream(searchText)
-	if err != nil {
-		t.Errorf("search stream failed: %v", err)
-	}
-	locations := []*Location{}
+	library := newTestLibrary(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	results, errs := library.SearchStream(ctx, searchText)
+	searched := []*SearchResult{}
 	limit := 100
 	for range limit {
-		location := <-results
-		locations = append(locations, location)
+		result := <-results
+		searched = append(searched, result)
+	}
+	cancel()
+	for range results {
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if l := len(searched); l != limit {
+		t.Errorf("expected %d results, got %d", limit, l)
+	}
+	if err := assertSearchResults(library, searched); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+}
+
+func TestLibrarySearchStreamWithMaxResults(t *testing.T) {
+	library := newTestLibrary(t)
+	results, errs := library.SearchStream(
+		context.Background(), searchText, WithMaxResults(25), WithWorkers(3),
+	)
+	searched := []*SearchResult{}
+	for result := range results {
+		searched = append(searched, result)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("search stream failed: %v", err)
+	}
+	if l := len(searched); l != 25 {
+		t.Errorf("expected 25 results, got %d", l)
+	}
+}
+
+func TestLibrarySearchStreamReportsErrors(t *testing.T) {
+	library := newTestLibrary(t)
+	results, errs := library.SearchStream(context.Background(), "hello!")
+	for range results {
+	}
+	if err := <-errs; err == nil {
+		t.Errorf("expected err for invalid characters, found nil")
+	}
+
+	results, errs = library.SearchStream(context.Background(), searchText, WithWorkers(0))
+	for range results {
+	}
+	if err := <-errs; err == nil {
+		t.Errorf("expected err for zero workers, found nil")
+	}
+}
+
+func TestLibrarySearchStreamReleasesGoroutines(t *testing.T) {
+	library := newTestLibrary(t)
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, errs := library.SearchStream(ctx, searchText, WithWorkers(8))
+	<-results
+	cancel()
+	for range results {
 	}
-	if l := len(locations); l != limit {
-		t.Errorf("expected %d locations, got %d", limit, l)
+	<-errs
+
+	// give exiting goroutines a moment to be reaped
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
 	}
-	if err := assertSearchedLocations(library, locations); err != nil {
-		t.Errorf("locations assertion failed: %v", err)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected at most %d goroutines after cancelling, got %d", before, after)
 	}
 }
 
 func TestLibrarySearchPagintated(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	limit, offset := 50, 0
 	results, err := library.SearchPaginated(searchText, offset, limit)
 	if err != nil {
@@ -42,8 +107,8 @@ func TestLibrarySearchPagintated(t *testing.T) {
 	if l := len(results); l != limit {
 		t.Errorf("expected %d results, got %d", limit, l)
 	}
-	if err := assertSearchedLocations(library, results); err != nil {
-		t.Errorf("locations assertion failed: %v", err)
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
 	}
 	offset = 50
 	results2, err := library.SearchPaginated(searchText, offset, limit)
@@ -53,13 +118,53 @@ func TestLibrarySearchPagintated(t *testing.T) {
 	if l := len(results2); l != limit {
 		t.Errorf("expected %d results, got %d", limit, l)
 	}
-	if err := assertSearchedLocations(library, results); err != nil {
-		t.Errorf("locations assertion failed: %v", err)
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+}
+
+func TestLibrarySearchStreamMatchesPaginatedOrder(t *testing.T) {
+	library := newTestLibrary(t)
+	limit := 60
+
+	paginated, err := library.SearchPaginated(searchText, 0, limit, WithWorkers(1))
+	if err != nil {
+		t.Fatalf("search paginated failed: %v", err)
+	}
+	parallel, err := library.SearchPaginated(searchText, 20, 40, WithWorkers(8))
+	if err != nil {
+		t.Fatalf("search paginated failed: %v", err)
+	}
+	results, errs := library.SearchStream(
+		context.Background(), searchText, WithMaxResults(limit), WithWorkers(8),
+	)
+	streamed := []*SearchResult{}
+	for result := range results {
+		streamed = append(streamed, result)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("search stream failed: %v", err)
+	}
+
+	if len(streamed) != limit {
+		t.Fatalf("expected %d streamed results, got %d", limit, len(streamed))
+	}
+	for i := range limit {
+		want := paginated[i]
+		if got := streamed[i]; got.Variant != i || !got.Location.Equals(*want.Location) {
+			t.Errorf("result %d: streamed %s, paginated %s", i, got.Location, want.Location)
+		}
+		if i < 20 {
+			continue
+		}
+		if got := parallel[i-20]; got.Variant != i || !got.Location.Equals(*want.Location) {
+			t.Errorf("result %d: parallel page %s, sequential page %s", i, got.Location, want.Location)
+		}
 	}
 }
 
 func TestLibrarySearchPaginatedWithInvalidLimit(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	limit, offset := -50, 0
 	_, err := library.SearchPaginated(searchText, offset, limit)
 	if err == nil {
@@ -68,7 +173,7 @@ func TestLibrarySearchPaginatedWithInvalidLimit(t *testing.T) {
 }
 
 func TestLibrarySearchPaginatedWithInvalidOffset(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	limit, offset := 50, -50
 	_, err := library.SearchPaginated(searchText, offset, limit)
 	if err == nil {
@@ -76,24 +181,136 @@ func TestLibrarySearchPaginatedWithInvalidOffset(t *testing.T) {
 	}
 }
 
-func assertSearchedLocations(library *Library, locations []*Location) error {
-	for _, location := range locations {
-		pageContent, err := library.Browse(location)
+func newTestLibrary(t *testing.T, opts ...Option) *Library {
+	t.Helper()
+	library, err := NewLibrary(opts...)
+	if err != nil {
+		t.Fatalf("failed to build library: %v", err)
+	}
+	return library
+}
+
+func assertSearchResults(library *Library, results []*SearchResult) error {
+	return assertSearchResultsFor(library, searchText, results)
+}
+
+func assertSearchResultsFor(library *Library, text string, results []*SearchResult) error {
+	for _, result := range results {
+		pageContent, err := library.Browse(result.Location)
 		if err != nil {
 			return fmt.Errorf("failed to browse location: %v", err)
 		}
-		if !strings.Contains(pageContent, searchText) {
-			return fmt.Errorf("location page does not contain search text: %s", searchText)
+		if !strings.Contains(pageContent, text) {
+			return fmt.Errorf("location page does not contain search text: %s", text)
+		}
+		if match := pageContent[result.Offset : result.Offset+result.Length]; match != text {
+			return fmt.Errorf("expected search text at offset %d, found %q", result.Offset, match)
+		}
+		if result.Snippet.Match != text {
+			return fmt.Errorf("snippet does not hold search text: %q", result.Snippet)
 		}
 	}
 	return nil
 }
 
+/*
+TESTING the bounds of the library's address space
+*/
+
+func TestLibraryAddressSpaceIsBijective(t *testing.T) {
+	binary, err := NewCharset("binary", "ab")
+	if err != nil {
+		t.Fatalf("failed to build charset: %v", err)
+	}
+	geometry := Geometry{
+		WallsPerHexagon: 1,
+		ShelvesPerWall:  1,
+		BooksPerShelf:   1,
+		PagesPerBook:    3,
+		LinesPerPage:    1,
+		CharsPerLine:    4,
+	}
+	library := newTestLibrary(t, WithCharset(binary), WithGeometry(geometry))
+
+	if got := library.PageCount().Int64(); got != 16 {
+		t.Fatalf("expected 16 pages, got %d", got)
+	}
+	if got := library.MaxHexagon().Int64(); got != 5 {
+		t.Fatalf("expected max hexagon 5, got %d", got)
+	}
+
+	seen := map[string]bool{}
+	for i := range int64(16) {
+		location := locationFromBase29Number(big.NewInt(i), geometry)
+		parsed, err := library.LocationFromString(location.String())
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", location, err)
+		}
+		page, err := library.Browse(parsed)
+		if err != nil {
+			t.Fatalf("failed to browse %s: %v", location, err)
+		}
+		if seen[page] {
+			t.Errorf("page %q reached from more than one address", page)
+		}
+		seen[page] = true
+
+		number, err := library.pageCharsToBase29Number([]rune(page))
+		if err != nil {
+			t.Fatalf("failed to read page %q: %v", page, err)
+		}
+		if number.Int64() != i {
+			t.Errorf("page %q read back as %d, want %d", page, number.Int64(), i)
+		}
+	}
+
+	// the last hexagon only holds a single page
+	for _, address := range []string{"5.0.0.0.2", "6.0.0.0.1"} {
+		_, err := library.LocationFromString(address)
+		var rangeErr *OutOfRangeError
+		if !errors.As(err, &rangeErr) {
+			t.Errorf("%s: expected OutOfRangeError, got %v", address, err)
+		}
+	}
+}
+
+func TestLibraryBrowseRejectsHexagonPastTheEnd(t *testing.T) {
+	library := newTestLibrary(t)
+	hexagon := new(big.Int).Add(library.MaxHexagon(), big.NewInt(1))
+	location := &Location{Hexagon: hexagon.Text(36), Page: 1}
+
+	_, err := library.Browse(location)
+	var rangeErr *OutOfRangeError
+	if !errors.As(err, &rangeErr) {
+		t.Errorf("expected OutOfRangeError, got %v", err)
+	}
+	if _, err := LocationFromString(location.String()); err == nil {
+		t.Errorf("got nil, expected err for hexagon past the end of the library")
+	}
+}
+
+func TestLibraryBrowseKeepsLeadingSpaces(t *testing.T) {
+	library := newTestLibrary(t)
+	page := strings.Repeat(" ", 100) + strings.Repeat("a", DefaultGeometry.CharsPerPage()-100)
+
+	number, err := library.pageCharsToBase29Number([]rune(page))
+	if err != nil {
+		t.Fatalf("failed to read page: %v", err)
+	}
+	browsed, err := library.Browse(locationFromBase29Number(number, DefaultGeometry))
+	if err != nil {
+		t.Fatalf("failed to browse: %v", err)
+	}
+	if browsed != page {
+		t.Errorf("page starting with spaces did not round trip")
+	}
+}
+
 /*
 TESTING Base29 encode and decoding
 */
 func TestLibraryBase29EncodeAndDecode(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	num, err := library.generateBase29Number(searchText, 0)
 	if err != nil {
 		t.Errorf("failed to encode text: %v", err)
@@ -109,7 +326,7 @@ func TestLibraryBase29EncodeAndDecode(t *testing.T) {
 }
 
 func TestLibraryBase29EmptyString(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	_, error := library.generateBase29Number("", 0)
 
 	if error == nil {
@@ -119,7 +336,7 @@ func TestLibraryBase29EmptyString(t *testing.T) {
 
 func TestLibraryBase29EncodeLongText(t *testing.T) {
 	text := strings.Repeat("hello", 1000)
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	_, error := library.generateBase29Number(text, 0)
 
 	if error == nil {
@@ -128,7 +345,7 @@ func TestLibraryBase29EncodeLongText(t *testing.T) {
 }
 
 func TestLibraryBase29EncodeInvalidChars(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	invalidChars := "!@#$%^&*()_+-=[]{}|;':\"<>?/~`"
 
 	for _, char := range invalidChars {
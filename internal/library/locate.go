@@ -0,0 +1,48 @@
+package library
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PageLengthError reports a page that does not hold exactly one page of characters
+type PageLengthError struct {
+	Length   int
+	Expected int
+}
+
+func (e *PageLengthError) Error() string {
+	return fmt.Sprintf("page must hold exactly %d characters, got %d", e.Expected, e.Length)
+}
+
+// Locate finds the exact address of a complete page of text, the inverse of Browse.
+// Line breaks are ignored so a page can be passed as printed in lines, every other character
+// must belong to the charset. Pages that are not exactly one page long are rejected
+// with a PageLengthError, see LocatePadded for short input.
+func (l Library) Locate(page string) (*Location, error) {
+	page = l.charset.Fold(stripLineBreaks(page))
+	if length := utf8.RuneCountInString(page); length != l.geometry.CharsPerPage() {
+		return nil, &PageLengthError{Length: length, Expected: l.geometry.CharsPerPage()}
+	}
+
+	bigInt, err := l.pageCharsToBase29Number([]rune(page))
+	if err != nil {
+		return nil, err
+	}
+	return l.locate(bigInt), nil
+}
+
+// LocatePadded is Locate for pages shorter than a full page, filling the rest of the page
+// with the charset's first symbol, a space in the preset charsets
+func (l Library) LocatePadded(page string) (*Location, error) {
+	page = stripLineBreaks(page)
+	if missing := l.geometry.CharsPerPage() - utf8.RuneCountInString(page); missing > 0 {
+		page += strings.Repeat(string(l.charset.symbols[0]), missing)
+	}
+	return l.Locate(page)
+}
+
+func stripLineBreaks(text string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(text)
+}
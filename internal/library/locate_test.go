@@ -0,0 +1,88 @@
+package library
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING reverse lookup of full pages
+*/
+
+func TestLocateInvertsBrowse(t *testing.T) {
+	for _, library := range []*Library{
+		newTestLibrary(t),
+		newTestLibrary(t, WithScrambling(scrambleKey)),
+		newTestLibrary(t, WithCharset(CharsetGreek), WithGeometry(smallGeometry)),
+	} {
+		for range 5 {
+			location := library.RandomLocation()
+			page, err := library.Browse(location)
+			if err != nil {
+				t.Fatalf("failed to browse: %v", err)
+			}
+			located, err := library.Locate(page)
+			if err != nil {
+				t.Fatalf("failed to locate page: %v", err)
+			}
+			if !located.Equals(*location) {
+				t.Errorf("got %s, want %s", located, location)
+			}
+		}
+	}
+}
+
+func TestLocateIgnoresLineBreaks(t *testing.T) {
+	library := newTestLibrary(t)
+	location := &Location{Hexagon: "3a7f", Wall: 2, Shelf: 3, Book: 15, Page: 204}
+	page, err := library.Browse(location)
+	if err != nil {
+		t.Fatalf("failed to browse: %v", err)
+	}
+
+	lines := []string{}
+	for i := 0; i < len(page); i += DefaultGeometry.CharsPerLine {
+		lines = append(lines, page[i:i+DefaultGeometry.CharsPerLine])
+	}
+	located, err := library.Locate(strings.Join(lines, "\r\n") + "\n")
+	if err != nil {
+		t.Fatalf("failed to locate page: %v", err)
+	}
+	if !located.Equals(*location) {
+		t.Errorf("got %s, want %s", located, location)
+	}
+}
+
+func TestLocateRejectsWrongLength(t *testing.T) {
+	library := newTestLibrary(t)
+	for _, page := range []string{searchText, strings.Repeat("a", 3201)} {
+		_, err := library.Locate(page)
+		var lengthErr *PageLengthError
+		if !errors.As(err, &lengthErr) {
+			t.Errorf("expected PageLengthError, got %v", err)
+		}
+	}
+	if _, err := library.Locate(strings.Repeat("!", 3200)); err == nil {
+		t.Errorf("got nil, expected err for invalid characters")
+	}
+}
+
+func TestLocatePadded(t *testing.T) {
+	library := newTestLibrary(t)
+	location, err := library.LocatePadded(searchText)
+	if err != nil {
+		t.Fatalf("failed to locate page: %v", err)
+	}
+	page, err := library.Browse(location)
+	if err != nil {
+		t.Fatalf("failed to browse: %v", err)
+	}
+	if want := searchText + strings.Repeat(" ", 3200-len(searchText)); page != want {
+		t.Errorf("padded page does not hold the text followed by spaces")
+	}
+
+	if _, err := library.LocatePadded(strings.Repeat("a", 3201)); err == nil {
+		t.Errorf("got nil, expected err for text longer than a page")
+	}
+}
@@ -1,57 +1,96 @@
 package library
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"math/big"
-	"math/rand"
 	"strconv"
 	"strings"
 )
 
-const (
-	maxHexagonCharSize = 3004
-	base36Chars        = "0123456789abcdefghijklmnopqrstuvwxyz"
-)
-
+// Location is the address of a page. Locations parsed or found by the library also keep
+// their hexagon as a number, so navigating from them does not parse Hexagon again.
 type Location struct {
 	Hexagon string
 	Wall    int
 	Shelf   int
 	Book    int
 	Page    int
+	// layout the location is addressed in, the zero value means DefaultGeometry
+	layout Geometry
+	// hexagon as a number, set by the library so navigating does not parse Hexagon again
+	hexagon hexagonCache
+}
+
+// hexagonCache is a hexagon's number in big-endian bytes, along with the string it was
+// read from, empty when unset. Reading bytes back is far cheaper than parsing base-36,
+// and strings keep locations of the same address comparable with ==.
+type hexagonCache struct {
+	text  string
+	bytes string
+}
+
+func newHexagonCache(n *big.Int) hexagonCache {
+	return hexagonCache{text: n.Text(36), bytes: string(n.Bytes())}
+}
+
+var (
+	// ErrStartOfLibrary reports moving before the library's first page, 0.0.0.0.1
+	ErrStartOfLibrary = errors.New("location is before the first page of the library")
+	// ErrEndOfLibrary reports moving past the library's last page
+	ErrEndOfLibrary = errors.New("location is past the last page of the library")
+)
+
+// OutOfRangeError reports a location past the last page of the library
+type OutOfRangeError struct {
+	Location   *Location
+	MaxHexagon *big.Int
+}
+
+func (e *OutOfRangeError) Error() string {
+	return fmt.Sprintf(
+		"location is outside the library: hexagons range from 0 to a %d character base-36 number",
+		len(e.MaxHexagon.Text(36)),
+	)
 }
 
 // Get Location from a period separated string: "<hexagon>.<wall>.<shelf>.<book>.<page>"
 func LocationFromString(address string) (*Location, error) {
+	return defaultLibrary.LocationFromString(address)
+}
+
+func locationFromString(address string, geometry Geometry) (*Location, error) {
 	parts := strings.Split(address, ".")
 	if partsLen := len(parts); partsLen != 5 {
 		return nil, fmt.Errorf("address is not of valid length, expected %d, got %d", 5, partsLen)
 	}
 
-	// validate hexagon
+	// validate hexagon, only the canonical spelling of a number is accepted so that every
+	// page has exactly one address
 	hexagon := parts[0]
-	if _, ok := new(big.Int).SetString(hexagon, 36); !ok {
+	n, ok := new(big.Int).SetString(hexagon, 36)
+	if !ok || n.Sign() < 0 || n.Text(36) != hexagon {
 		return nil, fmt.Errorf("invalid hexagon: must be valid base-36 string")
 	}
 
 	// parse and validate numeric parts
-	wall, err := parseAndValidate(parts[1], "wall", 0, wallsPerHexagon-1)
+	wall, err := parseAndValidate(parts[1], "wall", 0, geometry.WallsPerHexagon-1)
 	if err != nil {
 		return nil, err
 	}
 
-	shelf, err := parseAndValidate(parts[2], "shelf", 0, shelvesPerWall-1)
+	shelf, err := parseAndValidate(parts[2], "shelf", 0, geometry.ShelvesPerWall-1)
 	if err != nil {
 		return nil, err
 	}
 
-	book, err := parseAndValidate(parts[3], "book", 0, booksPerShelf-1)
+	book, err := parseAndValidate(parts[3], "book", 0, geometry.BooksPerShelf-1)
 	if err != nil {
 		return nil, err
 	}
 
-	page, err := parseAndValidate(parts[4], "page", 1, pagesPerBook)
+	page, err := parseAndValidate(parts[4], "page", 1, geometry.PagesPerBook)
 	if err != nil {
 		return nil, err
 	}
@@ -62,40 +101,45 @@ func LocationFromString(address string) (*Location, error) {
 		Shelf:   shelf,
 		Book:    book,
 		Page:    page,
+		layout:  geometry,
+		hexagon: hexagonCache{text: hexagon, bytes: string(n.Bytes())},
 	}, nil
 }
 
 // Determine a Location's given its big Int representation
-func locationFromBase29Number(n *big.Int) *Location {
+func locationFromBase29Number(n *big.Int, geometry Geometry) *Location {
 	temp, quotient := new(big.Int).Abs(n), new(big.Int)
 
 	// get page
 	page := new(big.Int)
-	quotient, page = quotient.DivMod(temp, big.NewInt(pagesPerBook), page)
+	quotient, page = quotient.DivMod(temp, big.NewInt(int64(geometry.PagesPerBook)), page)
 	temp.Set(quotient)
 
 	// get book
 	book := new(big.Int)
-	quotient, book = quotient.DivMod(temp, big.NewInt(booksPerShelf), book)
+	quotient, book = quotient.DivMod(temp, big.NewInt(int64(geometry.BooksPerShelf)), book)
 	temp.Set(quotient)
 
 	// get shelf
 	shelf := new(big.Int)
-	quotient, shelf = quotient.DivMod(temp, big.NewInt(shelvesPerWall), shelf)
+	quotient, shelf = quotient.DivMod(temp, big.NewInt(int64(geometry.ShelvesPerWall)), shelf)
 	temp.Set(quotient)
 
 	// get wall
 	wall := new(big.Int)
-	quotient, wall = quotient.DivMod(temp, big.NewInt(wallsPerHexagon), wall)
+	quotient, wall = quotient.DivMod(temp, big.NewInt(int64(geometry.WallsPerHexagon)), wall)
 	temp.Set(quotient)
 
+	// whatever is left from the quotient is the hexagon identifier
+	hexagon := newHexagonCache(quotient)
 	return &Location{
-		// whatever is left from the quotient is the hexagon identifier
-		Hexagon: quotient.Text(36),
+		Hexagon: hexagon.text,
 		Wall:    int(wall.Int64()),
 		Shelf:   int(shelf.Int64()),
 		Book:    int(book.Int64()),
 		Page:    int(page.Int64()) + 1,
+		layout:  geometry,
+		hexagon: hexagon,
 	}
 }
 
@@ -110,30 +154,48 @@ func parseAndValidate(s string, name string, min, max int) (int, error) {
 	return num, nil
 }
 
+// Geometry returns the layout the location is addressed in
+func (l Location) Geometry() Geometry {
+	if l.layout == (Geometry{}) {
+		return DefaultGeometry
+	}
+	return l.layout
+}
+
+// hexagonNumber reads the location's hexagon as a number, from the cache when it holds
+// the current Hexagon, which a caller may have changed since
+func (l Location) hexagonNumber() (*big.Int, bool) {
+	if l.hexagon.text != "" && l.hexagon.text == l.Hexagon {
+		return new(big.Int).SetBytes([]byte(l.hexagon.bytes)), true
+	}
+	return new(big.Int).SetString(l.Hexagon, 36)
+}
+
 // Get Location from a big.Int
 func (l Location) ToBigInt() (*big.Int, error) {
-	hexagon, ok := new(big.Int).SetString(l.Hexagon, 36)
+	hexagon, ok := l.hexagonNumber()
 	if !ok {
 		return nil, errors.New("invalid hexagon string format")
 	}
+	geometry := l.Geometry()
 
 	// build up from hexagon
 	result := new(big.Int).Set(hexagon)
 
 	// add wall
-	result.Mul(result, big.NewInt(wallsPerHexagon))
+	result.Mul(result, big.NewInt(int64(geometry.WallsPerHexagon)))
 	result.Add(result, big.NewInt(int64(l.Wall)))
 
 	// add shelf
-	result.Mul(result, big.NewInt(shelvesPerWall))
+	result.Mul(result, big.NewInt(int64(geometry.ShelvesPerWall)))
 	result.Add(result, big.NewInt(int64(l.Shelf)))
 
 	// add book
-	result.Mul(result, big.NewInt(booksPerShelf))
+	result.Mul(result, big.NewInt(int64(geometry.BooksPerShelf)))
 	result.Add(result, big.NewInt(int64(l.Book)))
 
 	// add page
-	result.Mul(result, big.NewInt(pagesPerBook))
+	result.Mul(result, big.NewInt(int64(geometry.PagesPerBook)))
 	result.Add(result, big.NewInt(int64(l.Page)-1))
 
 	return result, nil
@@ -144,7 +206,8 @@ func (l Location) Equals(other Location) bool {
 		l.Wall == other.Wall &&
 		l.Shelf == other.Shelf &&
 		l.Book == other.Book &&
-		l.Page == other.Page
+		l.Page == other.Page &&
+		l.Geometry() == other.Geometry()
 }
 
 func (l Location) String() string {
@@ -159,48 +222,52 @@ func (l Location) Next() *Location {
 		Shelf:   l.Shelf,
 		Book:    l.Book,
 		Page:    l.Page,
+		layout:  l.layout,
+		hexagon: l.hexagon,
 	}
+	geometry := l.Geometry()
 
 	// increment page
-	if next.Page < pagesPerBook {
+	if next.Page < geometry.PagesPerBook {
 		next.Page++
 		return &next
 	}
 
 	// page is at max, increment book
 	next.Page = 1
-	if next.Book < booksPerShelf-1 {
+	if next.Book < geometry.BooksPerShelf-1 {
 		next.Book++
 		return &next
 	}
 
 	// book is at max, increment shelf
 	next.Book = 0
-	if next.Shelf < shelvesPerWall-1 {
+	if next.Shelf < geometry.ShelvesPerWall-1 {
 		next.Shelf++
 		return &next
 	}
 
 	// shelf is at max, increment wall
 	next.Shelf = 0
-	if next.Wall < wallsPerHexagon-1 {
+	if next.Wall < geometry.WallsPerHexagon-1 {
 		next.Wall++
 		return &next
 	}
 
 	// wall is at max, increment hexagon
 	next.Wall = 0
-	hexInt, ok := new(big.Int).SetString(next.Hexagon, 36)
+	hexInt, ok := next.hexagonNumber()
 	if !ok {
 		return &next
 	}
-	hexInt.Add(hexInt, big.NewInt(1))
-	next.Hexagon = hexInt.Text(36)
+	next.hexagon = newHexagonCache(new(big.Int).Add(hexInt, big.NewInt(1)))
+	next.Hexagon = next.hexagon.text
 
 	return &next
 }
 
-// Previous returns the previous page location
+// Previous returns the previous page location. The library's first page has no previous
+// page and returns itself, see Advance to detect the start of the library.
 func (l Location) Previous() *Location {
 	prev := Location{
 		Hexagon: l.Hexagon,
@@ -208,6 +275,13 @@ func (l Location) Previous() *Location {
 		Shelf:   l.Shelf,
 		Book:    l.Book,
 		Page:    l.Page,
+		layout:  l.layout,
+		hexagon: l.hexagon,
+	}
+	geometry := l.Geometry()
+
+	if prev.Hexagon == "0" && prev.Wall == 0 && prev.Shelf == 0 && prev.Book == 0 && prev.Page == 1 {
+		return &prev
 	}
 
 	// decrement page
@@ -217,52 +291,87 @@ func (l Location) Previous() *Location {
 	}
 
 	// page is at min, decrement book
-	prev.Page = pagesPerBook
+	prev.Page = geometry.PagesPerBook
 	if prev.Book > 0 {
 		prev.Book--
 		return &prev
 	}
 
 	// book is at min, decrement shelf
-	prev.Book = booksPerShelf - 1
+	prev.Book = geometry.BooksPerShelf - 1
 	if prev.Shelf > 0 {
 		prev.Shelf--
 		return &prev
 	}
 
 	// shelf is at min, decrement wall
-	prev.Shelf = shelvesPerWall - 1
+	prev.Shelf = geometry.ShelvesPerWall - 1
 	if prev.Wall > 0 {
 		prev.Wall--
 		return &prev
 	}
 
 	// wall is at min, decrement hexagon
-	prev.Wall = wallsPerHexagon - 1
-	hexInt, ok := new(big.Int).SetString(prev.Hexagon, 36)
+	prev.Wall = geometry.WallsPerHexagon - 1
+	hexInt, ok := prev.hexagonNumber()
 	if !ok || hexInt.Sign() <= 0 {
 		return &prev
 	}
-	hexInt.Sub(hexInt, big.NewInt(1))
-	prev.Hexagon = hexInt.Text(36)
+	prev.hexagon = newHexagonCache(new(big.Int).Sub(hexInt, big.NewInt(1)))
+	prev.Hexagon = prev.hexagon.text
 
 	return &prev
 }
 
-// Random generates a random location in the library
-func RandomLocation() *Location {
-	// Generate random base36 string of random length
-	hexagonLen := rand.Intn(maxHexagonCharSize) + 1 //nolint: gosec
-	hexagon := make([]byte, hexagonLen)
-	for i := range hexagon {
-		hexagon[i] = base36Chars[rand.Intn(36)] //nolint:gosec
+// Advance moves n pages on from the location, back when n is negative, running from book
+// to book and hexagon to hexagon as Next does. Moving before the first page fails with
+// ErrStartOfLibrary. A location does not know its library's charset, and so where the
+// library ends, see Library.Advance to stop at the last page.
+func (l Location) Advance(n *big.Int) (*Location, error) {
+	index, err := l.ToBigInt()
+	if err != nil {
+		return nil, err
 	}
+	index.Add(index, n)
+	if index.Sign() < 0 {
+		return nil, ErrStartOfLibrary
+	}
+	return locationFromBase29Number(index, l.Geometry()), nil
+}
 
-	return &Location{
-		Hexagon: string(hexagon),
-		Wall:    rand.Intn(wallsPerHexagon),  //nolint: gosec
-		Shelf:   rand.Intn(shelvesPerWall),   //nolint: gosec
-		Book:    rand.Intn(booksPerShelf),    //nolint: gosec
-		Page:    rand.Intn(pagesPerBook) + 1, // nolint: gosec
+// Compare orders locations the way the library shelves them, returning -1 when the
+// location comes before other, 1 when it comes after and 0 when they are the same page.
+// Hexagons are compared as written, without parsing them, so both locations should be
+// canonical addresses in the same geometry, as every parsed and searched location is.
+func (l Location) Compare(other Location) int {
+	return cmp.Or(
+		cmp.Compare(len(l.Hexagon), len(other.Hexagon)),
+		cmp.Compare(l.Hexagon, other.Hexagon),
+		cmp.Compare(l.Wall, other.Wall),
+		cmp.Compare(l.Shelf, other.Shelf),
+		cmp.Compare(l.Book, other.Book),
+		cmp.Compare(l.Page, other.Page),
+	)
+}
+
+// Distance is the number of pages from a to b, negative when b comes before a, so that
+// a.Advance(Distance(a, b)) is b. Both locations must share a geometry.
+func Distance(a, b Location) (*big.Int, error) {
+	if a.Geometry() != b.Geometry() {
+		return nil, errors.New("locations are addressed in different geometries")
 	}
+	start, err := a.ToBigInt()
+	if err != nil {
+		return nil, err
+	}
+	end, err := b.ToBigInt()
+	if err != nil {
+		return nil, err
+	}
+	return end.Sub(end, start), nil
+}
+
+// Random generates a random location in the library
+func RandomLocation() *Location {
+	return defaultLibrary.RandomLocation()
 }
@@ -1,8 +1,10 @@
 package library
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"testing"
 )
 
@@ -57,6 +59,15 @@ func TestLocationFromInvalidHexagonString(t *testing.T) {
 	}
 }
 
+func TestLocationFromNonCanonicalHexagonString(t *testing.T) {
+	for _, hexagon := range []string{"0abc", "ABC", "-1"} {
+		input := fmt.Sprintf("%s.2.1.12.30", hexagon)
+		if _, err := LocationFromString(input); err == nil {
+			t.Errorf("got nil, expected err for input %s", input)
+		}
+	}
+}
+
 func TestLocationFromStringWithInvalidWallValue(t *testing.T) {
 	// Too big
 	_, err := LocationFromString(fmt.Sprintf("%s.30.1.12.30", big.NewInt(0).Text(36)))
@@ -118,14 +129,14 @@ func TestLocationFromStringWithInvalidPageValue(t *testing.T) {
 */
 
 func TestGetLocationFromBigInt(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 
 	originalNum, err := library.generateBase29Number("Hello world", 0)
 	if err != nil {
 		t.Errorf("failed to base29 encode: %v", err)
 	}
 
-	location := locationFromBase29Number(originalNum)
+	location := locationFromBase29Number(originalNum, DefaultGeometry)
 	number, err := location.ToBigInt()
 	if err != nil {
 		t.Errorf("location to big.Int conversion failed: %v", err)
@@ -137,12 +148,12 @@ func TestGetLocationFromBigInt(t *testing.T) {
 }
 
 func TestGetLocationWithInvalidHexagonString(t *testing.T) {
-	library := NewLibrary()
+	library := newTestLibrary(t)
 	number, err := library.generateBase29Number("Hello world", 0)
 	if err != nil {
 		t.Errorf("failed to base29 encode: %v", err)
 	}
-	location := locationFromBase29Number(number)
+	location := locationFromBase29Number(number, DefaultGeometry)
 	location.Hexagon = "invalid base32 string"
 	_, err2 := location.ToBigInt()
 	if err2 == nil {
@@ -451,3 +462,152 @@ func TestLocationNextPrevious_RoundTrip(t *testing.T) {
 		t.Errorf("Previous().Next() roundtrip failed: got %+v, want %+v", roundTrip2, location)
 	}
 }
+
+func TestLocationPrevious_StartOfLibrary(t *testing.T) {
+	location := Location{Hexagon: "0", Wall: 0, Shelf: 0, Book: 0, Page: 1}
+	if prev := location.Previous(); !prev.Equals(location) {
+		t.Errorf("got %+v, want the first page to stay put", prev)
+	}
+}
+
+/*
+	TESTING location arithmetic
+*/
+
+func TestLocationAdvance(t *testing.T) {
+	location := Location{Hexagon: "3a7f", Wall: 2, Shelf: 3, Book: 15, Page: 204}
+	geometry := DefaultGeometry
+
+	// 1,000 books ahead crosses two hexagons of 640 books
+	ahead, err := location.Advance(big.NewInt(int64(1000 * geometry.PagesPerBook)))
+	if err != nil {
+		t.Fatalf("failed to advance: %v", err)
+	}
+	expected := Location{Hexagon: "3a7h", Wall: 0, Shelf: 4, Book: 23, Page: 204}
+	if !ahead.Equals(expected) {
+		t.Errorf("got %s, want %s", ahead, expected)
+	}
+
+	back, err := ahead.Advance(big.NewInt(int64(-1000 * geometry.PagesPerBook)))
+	if err != nil {
+		t.Fatalf("failed to advance: %v", err)
+	}
+	if !back.Equals(location) {
+		t.Errorf("got %s, want %s", back, location)
+	}
+	if same, err := location.Advance(big.NewInt(0)); err != nil || !same.Equals(location) {
+		t.Errorf("got %v and err %v, want %s", same, err, location)
+	}
+	if next, err := location.Advance(big.NewInt(1)); err != nil || !next.Equals(*location.Next()) {
+		t.Errorf("got %v and err %v, want %s", next, err, location.Next())
+	}
+}
+
+func TestLocationAdvanceOutOfLibrary(t *testing.T) {
+	first := Location{Hexagon: "0", Wall: 0, Shelf: 0, Book: 0, Page: 1}
+	if _, err := first.Advance(big.NewInt(-1)); !errors.Is(err, ErrStartOfLibrary) {
+		t.Errorf("got %v, want ErrStartOfLibrary", err)
+	}
+
+	library := newTestLibrary(t, WithGeometry(smallGeometry))
+	first.layout = smallGeometry
+	if _, err := library.Advance(&first, big.NewInt(-1)); !errors.Is(err, ErrStartOfLibrary) {
+		t.Errorf("got %v, want ErrStartOfLibrary", err)
+	}
+	last := locationFromBase29Number(new(big.Int).Sub(library.PageCount(), big.NewInt(1)), smallGeometry)
+	if _, err := library.Advance(last, big.NewInt(1)); !errors.Is(err, ErrEndOfLibrary) {
+		t.Errorf("got %v, want ErrEndOfLibrary", err)
+	}
+	back := new(big.Int).Sub(big.NewInt(1), library.PageCount())
+	if got, err := library.Advance(last, back); err != nil || !got.Equals(first) {
+		t.Errorf("got %v and err %v, want %s", got, err, first)
+	}
+}
+
+func TestLocationCompareAndDistance(t *testing.T) {
+	addresses := []string{"10.0.0.0.1", "z.3.4.31.410", "0.0.0.0.2", "10.0.0.0.1", "0.0.0.0.1", "z.3.4.31.409", "a0.0.0.0.1"}
+	locations := []*Location{}
+	for _, address := range addresses {
+		location, err := LocationFromString(address)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", address, err)
+		}
+		locations = append(locations, location)
+	}
+	slices.SortFunc(locations, func(a, b *Location) int {
+		return a.Compare(*b)
+	})
+
+	sorted := []string{}
+	for i, location := range locations {
+		sorted = append(sorted, location.String())
+		if i == 0 {
+			continue
+		}
+		// sorting by Compare agrees with the distance between pages
+		distance, err := Distance(*locations[i-1], *location)
+		if err != nil {
+			t.Fatalf("failed to measure distance: %v", err)
+		}
+		if distance.Sign() < 0 {
+			t.Errorf("%s comes after %s, %s pages apart", location, locations[i-1], distance)
+		}
+		if moved, err := locations[i-1].Advance(distance); err != nil || !moved.Equals(*location) {
+			t.Errorf("advancing %s by %s pages got %v, want %s", locations[i-1], distance, moved, location)
+		}
+	}
+	want := []string{"0.0.0.0.1", "0.0.0.0.2", "z.3.4.31.409", "z.3.4.31.410", "10.0.0.0.1", "10.0.0.0.1", "a0.0.0.0.1"}
+	if !slices.Equal(sorted, want) {
+		t.Errorf("got %v, want %v", sorted, want)
+	}
+
+	distance, err := Distance(*locations[6], *locations[0])
+	if err != nil {
+		t.Fatalf("failed to measure distance: %v", err)
+	}
+	// hexagon a0 is 360 hexagons on from the first page
+	if expected := big.NewInt(int64(-360 * DefaultGeometry.PagesPerHexagon())); distance.Cmp(expected) != 0 {
+		t.Errorf("got %s, want %s", distance, expected)
+	}
+
+	other := Location{Hexagon: "0", Page: 1, layout: smallGeometry}
+	if _, err := Distance(*locations[0], other); err == nil {
+		t.Errorf("got nil, expected err for different geometries")
+	}
+}
+
+func TestLocationCachesHexagon(t *testing.T) {
+	library := newTestLibrary(t)
+	location := library.RandomLocation()
+	if location.hexagon.text != location.Hexagon {
+		t.Fatalf("library location does not cache its hexagon")
+	}
+	// locations of the same address stay equal, and usable as map keys
+	if parsed, err := library.LocationFromString(location.String()); err != nil || *parsed != *location {
+		t.Errorf("got %+v and err %v, want %+v", parsed, err, location)
+	}
+	parsed := Location{Hexagon: location.Hexagon, Wall: location.Wall, Shelf: location.Shelf, Book: location.Book, Page: location.Page}
+	want, err := parsed.ToBigInt()
+	if err != nil {
+		t.Fatalf("failed to convert location: %v", err)
+	}
+
+	// walking across hexagons keeps the cache in step with the address
+	for range DefaultGeometry.PagesPerHexagon() + 3 {
+		location = location.Next()
+	}
+	for range DefaultGeometry.PagesPerHexagon() + 3 {
+		location = location.Previous()
+	}
+	if got, err := location.ToBigInt(); err != nil || got.Cmp(want) != 0 {
+		t.Errorf("got %v and err %v after walking, want %v", got, err, want)
+	}
+
+	// a hexagon changed by hand is read again
+	location.Hexagon = "1"
+	pagesPerHexagon := big.NewInt(int64(DefaultGeometry.PagesPerHexagon()))
+	want.Mod(want, pagesPerHexagon).Add(want, pagesPerHexagon)
+	if got, err := location.ToBigInt(); err != nil || got.Cmp(want) != 0 {
+		t.Errorf("got %v and err %v, want %v in hexagon 1", got, err, want)
+	}
+}
@@ -0,0 +1,128 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// version of the binary form of a Location, its first byte
+const locationBinaryVersion = 1
+
+// MarshalText writes the location as its address, "<hexagon>.<wall>.<shelf>.<book>.<page>",
+// so it can be a URL parameter, a JSON field or a JSON map key
+func (l Location) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses an address written by MarshalText, validating it as
+// LocationFromString does in the geometry the location already has, DefaultGeometry for
+// the zero Location. Like LocationFromString, it rejects addresses past the last page of
+// a library written in CharsetDefault, see Library.UnmarshalLocation for other charsets.
+func (l *Location) UnmarshalText(text []byte) error {
+	location, err := locationFromString(string(text), l.Geometry())
+	if err != nil {
+		return err
+	}
+	if err := checkInLibrary(location, CharsetDefault); err != nil {
+		return err
+	}
+	*l = *location
+	return nil
+}
+
+// MarshalJSON writes the location as a JSON string holding its address
+func (l Location) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+// UnmarshalJSON reads a JSON string holding an address, see UnmarshalText
+func (l *Location) UnmarshalJSON(data []byte) error {
+	var address string
+	if err := json.Unmarshal(data, &address); err != nil {
+		return fmt.Errorf("location must be a JSON string: %w", err)
+	}
+	return l.UnmarshalText([]byte(address))
+}
+
+// MarshalBinary writes the location as a version byte followed by its page number in
+// big-endian bytes, about two thirds the size of its address
+func (l Location) MarshalBinary() ([]byte, error) {
+	n, err := l.ToBigInt()
+	if err != nil {
+		return nil, err
+	}
+	if n.Sign() < 0 {
+		return nil, errors.New("location has a negative page number")
+	}
+	return append([]byte{locationBinaryVersion}, n.Bytes()...), nil
+}
+
+// UnmarshalBinary reads a location written by MarshalBinary, in the geometry the
+// location already has, DefaultGeometry for the zero Location. Page numbers with leading
+// zero bytes are rejected so every location has a single binary form, and page numbers
+// past the last page as UnmarshalText rejects them.
+func (l *Location) UnmarshalBinary(data []byte) error {
+	location, err := locationFromBinary(data, l.Geometry())
+	if err != nil {
+		return err
+	}
+	if err := checkInLibrary(location, CharsetDefault); err != nil {
+		return err
+	}
+	*l = *location
+	return nil
+}
+
+// UnmarshalLocation reads a location written by MarshalText or MarshalBinary, laid out in
+// the library's geometry, rejecting locations past the library's last page. The binary
+// form starts with its version byte, which no address starts with.
+func (l Library) UnmarshalLocation(data []byte) (*Location, error) {
+	decode := func(data []byte, geometry Geometry) (*Location, error) {
+		return locationFromString(string(data), geometry)
+	}
+	if len(data) > 0 && data[0] == locationBinaryVersion {
+		decode = locationFromBinary
+	}
+	location, err := decode(data, l.geometry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.pageNumber(location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// locationFromBinary reads the binary form of a location laid out in geometry
+func locationFromBinary(data []byte, geometry Geometry) (*Location, error) {
+	if len(data) == 0 {
+		return nil, errors.New("binary location should not be empty")
+	}
+	if version := data[0]; version != locationBinaryVersion {
+		return nil, fmt.Errorf("unsupported binary location version %d", version)
+	}
+	if len(data) > 1 && data[1] == 0 {
+		return nil, errors.New("binary location has leading zero bytes")
+	}
+	return locationFromBase29Number(new(big.Int).SetBytes(data[1:]), geometry), nil
+}
+
+// checkInLibrary rejects a location past the last page of a library written in charset and
+// laid out in the location's geometry, without building the library
+func checkInLibrary(location *Location, charset *Charset) error {
+	geometry := location.Geometry()
+	count := defaultLibrary.pageCount
+	if charset != defaultLibrary.charset || geometry != defaultLibrary.geometry {
+		count = pageCount(charset, geometry)
+	}
+	n, err := location.ToBigInt()
+	if err != nil {
+		return err
+	}
+	if n.Sign() < 0 || n.Cmp(count) >= 0 {
+		return &OutOfRangeError{Location: location, MaxHexagon: maxHexagon(count, geometry)}
+	}
+	return nil
+}
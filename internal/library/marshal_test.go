@@ -0,0 +1,174 @@
+package library
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+/*
+	TESTING text, JSON and binary encodings of locations
+*/
+
+func TestLocationTextRoundTrip(t *testing.T) {
+	location := defaultLibrary.RandomLocation()
+	text, err := location.MarshalText()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if string(text) != location.String() {
+		t.Errorf("got %s, want %s", text, location)
+	}
+	var decoded Location
+	if err := decoded.UnmarshalText(text); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if decoded != *location {
+		t.Errorf("got %s, want %s", decoded, location)
+	}
+}
+
+func TestLocationJSON(t *testing.T) {
+	location, err := LocationFromString("3a7f.2.3.15.204")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	type page struct {
+		Location  Location            `json:"location"`
+		Neighbour *Location           `json:"neighbour"`
+		Notes     map[Location]string `json:"notes"`
+	}
+	data, err := json.Marshal(page{
+		Location:  *location,
+		Neighbour: location.Next(),
+		Notes:     map[Location]string{*location: "here"},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	want := `{"location":"3a7f.2.3.15.204","neighbour":"3a7f.2.3.15.205","notes":{"3a7f.2.3.15.204":"here"}}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	var decoded page
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if decoded.Location != *location || !decoded.Neighbour.Equals(*location.Next()) || decoded.Notes[*location] != "here" {
+		t.Errorf("got %+v, want the page marshaled", decoded)
+	}
+}
+
+func TestLocationJSONValidates(t *testing.T) {
+	for _, data := range []string{
+		`"3a7f.2.3.15"`,
+		`"3a7f.4.3.15.204"`,
+		`"3a7f.2.3.15.0"`,
+		`"03a7f.2.3.15.204"`,
+		`"3A7F.2.3.15.204"`,
+		`{"Hexagon":"3a7f","Wall":2,"Shelf":3,"Book":15,"Page":204}`,
+		`"` + new(big.Int).Add(defaultLibrary.MaxHexagon(), big.NewInt(1)).Text(36) + `.0.0.0.1"`,
+		`42`,
+	} {
+		var location Location
+		if err := json.Unmarshal([]byte(data), &location); err == nil {
+			t.Errorf("%s: got nil, expected err", data)
+		}
+	}
+}
+
+func TestLocationBinaryRoundTrip(t *testing.T) {
+	for _, location := range []*Location{
+		defaultLibrary.RandomLocation(),
+		{Hexagon: "0", Wall: 0, Shelf: 0, Book: 0, Page: 1},
+		{Hexagon: "1", Wall: 3, Shelf: 4, Book: 31, Page: 410},
+	} {
+		data, err := location.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if len(data) > len(location.String()) {
+			t.Errorf("binary form of %d bytes is longer than the address", len(data))
+		}
+		var decoded Location
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("failed to unmarshal: %v", err)
+		}
+		if !decoded.Equals(*location) {
+			t.Errorf("got %s, want %s", decoded, location)
+		}
+	}
+
+	// the geometry of the location decoded into is kept
+	location := locationFromBase29Number(big.NewInt(1000), smallGeometry)
+	data, err := location.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	decoded := Location{layout: smallGeometry}
+	if err := decoded.UnmarshalBinary(data); err != nil || decoded != *location {
+		t.Errorf("got %s and err %v, want %s", decoded, err, location)
+	}
+}
+
+func TestLocationBinaryValidates(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":         {},
+		"version":       {2, 1},
+		"leading zeros": {locationBinaryVersion, 0, 1},
+		"past the end":  append([]byte{locationBinaryVersion}, defaultLibrary.PageCount().Bytes()...),
+	} {
+		var location Location
+		if err := location.UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+
+	// the last page depends on the geometry decoded into
+	small := newTestLibrary(t, WithGeometry(smallGeometry))
+	location := Location{layout: smallGeometry}
+	if err := location.UnmarshalBinary(append([]byte{locationBinaryVersion}, small.PageCount().Bytes()...)); err == nil {
+		t.Error("got nil, expected err past the last page of the small geometry")
+	}
+}
+
+func TestLibraryUnmarshalLocation(t *testing.T) {
+	// hexagons of a larger charset run far past the last page of CharsetDefault
+	library := newTestLibrary(t, WithCharset(CharsetAlphanumeric), WithGeometry(smallGeometry))
+	location := library.RandomLocation()
+	for {
+		if n, _ := location.ToBigInt(); n.Cmp(pageCount(CharsetDefault, smallGeometry)) >= 0 {
+			break
+		}
+		location = library.RandomLocation()
+	}
+	text, _ := location.MarshalText()
+	binary, _ := location.MarshalBinary()
+	for name, data := range map[string][]byte{"text": text, "binary": binary} {
+		decoded, err := library.UnmarshalLocation(data)
+		if err != nil {
+			t.Fatalf("%s: failed to unmarshal: %v", name, err)
+		}
+		if *decoded != *location {
+			t.Errorf("%s: got %s, want %s", name, decoded, location)
+		}
+	}
+
+	past := append([]byte{locationBinaryVersion}, library.PageCount().Bytes()...)
+	borges := newTestLibrary(t, WithCharset(CharsetBorges))
+	for name, test := range map[string]struct {
+		library *Library
+		data    []byte
+	}{
+		"past the end":        {library, past},
+		"past a smaller end":  {borges, []byte(defaultLibrary.MaxHexagon().Text(36) + ".0.0.0.1")},
+		"invalid address":     {library, []byte("3a7f.2.3")},
+		"empty":               {library, nil},
+		"binary leading zero": {library, []byte{locationBinaryVersion, 0, 1}},
+	} {
+		if _, err := test.library.UnmarshalLocation(test.data); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package library
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// InvalidCharacterError reports the first character of a text that the charset cannot write
+type InvalidCharacterError struct {
+	Char rune
+	// index of the character in the text, counting characters from 0
+	Index   int
+	Charset *Charset
+}
+
+func (e *InvalidCharacterError) Error() string {
+	return fmt.Sprintf("text contains invalid character %q at index %d, supported charset: %v",
+		e.Char, e.Index, e.Charset)
+}
+
+// Change is a character Normalize rewrote
+type Change struct {
+	// index of the character in the original text, counting characters from 0
+	Index int
+	From  string
+	// what the character was rewritten into, empty when it was dropped
+	To string
+}
+
+// digits spelled out, each digit on its own
+var digitNames = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+
+// symbols spelled out as words
+var symbolNames = map[rune]string{
+	'&': "and", '@': "at", '%': "percent", '+': "plus", '=': "equals", '#': "number",
+	'$': "dollar", '€': "euro", '£': "pound",
+}
+
+// letters with no decomposition into a base letter and marks, and punctuation written
+// with the preset charsets' comma and period
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D", 'þ': "th", 'Þ': "TH", 'ð': "d", 'Ð': "D",
+	'!': ".", '?': ".", ';': ",", ':': ",", '…': "...", '-': " ", '–': " ", '—': " ",
+}
+
+// NormalizeOption tunes how Normalize rewrites text
+type NormalizeOption func(*normalizeConfig)
+
+type normalizeConfig struct {
+	mapping map[rune]string
+	kept    string
+	strict  bool
+}
+
+// WithMapping rewrites each character of mapping into its value before any other step,
+// taking precedence over the built in tables. Values must be written in the charset, or
+// with characters WithKept keeps, and Normalize fails on a value that is not.
+func WithMapping(mapping map[rune]string) NormalizeOption {
+	return func(c *normalizeConfig) {
+		maps.Copy(c.mapping, mapping)
+	}
+}
+
+// WithKept leaves every character of chars untouched, e.g. wildcards or the line breaks
+// of block text
+func WithKept(chars string) NormalizeOption {
+	return func(c *normalizeConfig) {
+		c.kept += chars
+	}
+}
+
+// WithStrict rewrites nothing, Normalize instead fails with an InvalidCharacterError on
+// the first character the charset cannot write and WithKept does not keep
+func WithStrict() NormalizeOption {
+	return func(c *normalizeConfig) {
+		c.strict = true
+	}
+}
+
+// Normalize rewrites text into characters of the library's charset, so it can be
+// searched, and lists every character it changed. Each character outside the charset
+// goes through the first step that writes it in the charset:
+//
+//   - the mapping set with WithMapping
+//   - line breaks, tabs and other white space become spaces
+//   - letters fold to the charset's case
+//   - letters lose their diacritics, ligatures and letters like ß are spelled out
+//   - digits and symbols like & are spelled out as words, e.g. "4 & 2" reads "four and two"
+//   - punctuation becomes the charset's comma and period, dashes become spaces
+//
+// Characters no step can write are dropped.
+func (l Library) Normalize(text string, opts ...NormalizeOption) (string, []Change, error) {
+	config := normalizeConfig{mapping: map[rune]string{}}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.strict {
+		if err := l.checkCharset(text, config.kept); err != nil {
+			return "", nil, err
+		}
+		return text, []Change{}, nil
+	}
+	for _, char := range slices.Sorted(maps.Keys(config.mapping)) {
+		if err := l.checkCharset(config.mapping[char], config.kept); err != nil {
+			return "", nil, fmt.Errorf("mapping of %q: %w", char, err)
+		}
+	}
+
+	runes := []rune(text)
+	var normalized strings.Builder
+	changes := []Change{}
+	space := l.charset.Contains(' ')
+	for i := 0; i < len(runes); i++ {
+		index, char := i, runes[i]
+		// a Windows line break is a single line break
+		if char == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+			char = '\n'
+			i++
+		}
+		from := string(runes[index : i+1])
+
+		replacement, word := string(char), false
+		if !l.charset.Contains(char) && !strings.ContainsRune(config.kept, char) {
+			replacement, word = l.rewrite(char, config)
+		}
+		if word && space {
+			if normalized.Len() > 0 && !strings.HasSuffix(normalized.String(), " ") {
+				replacement = " " + replacement
+			}
+			if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && !unicode.IsPunct(runes[i+1]) {
+				replacement += " "
+			}
+		}
+		normalized.WriteString(replacement)
+		if replacement != from {
+			changes = append(changes, Change{Index: index, From: from, To: replacement})
+		}
+	}
+	return normalized.String(), changes, nil
+}
+
+// rewrite writes a character outside the charset with the charset's characters, reporting
+// whether it was spelled out as a word. It is empty when no step can write it.
+func (l Library) rewrite(char rune, config normalizeConfig) (string, bool) {
+	if mapped, ok := config.mapping[char]; ok {
+		return mapped, false
+	}
+	if unicode.IsSpace(char) && l.charset.Contains(' ') {
+		return " ", false
+	}
+	if folded := l.charset.Fold(string(char)); l.writable(folded) {
+		return folded, false
+	}
+	if spelled, ok := transliterations[char]; ok && l.writable(l.charset.Fold(spelled)) {
+		return l.charset.Fold(spelled), false
+	}
+	// the base letters of a decomposed character, without its marks
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(string(char)))
+	if stripped = l.charset.Fold(stripped); stripped != string(char) && l.writable(stripped) {
+		return stripped, false
+	}
+	if char >= '0' && char <= '9' && l.writable(l.charset.Fold(digitNames[char-'0'])) {
+		return l.charset.Fold(digitNames[char-'0']), true
+	}
+	if name, ok := symbolNames[char]; ok && l.writable(l.charset.Fold(name)) {
+		return l.charset.Fold(name), true
+	}
+	return "", false
+}
+
+// writable reports whether every character of text belongs to the charset
+func (l Library) writable(text string) bool {
+	return text != "" && l.checkCharset(text, "") == nil
+}
+
+// checkCharset fails with an InvalidCharacterError on the first character of text the
+// charset cannot write once its case is folded, skipping the characters of skip
+func (l Library) checkCharset(text, skip string) error {
+	folded := []rune(l.charset.Fold(text))
+	for i, char := range []rune(text) {
+		if !l.charset.Contains(folded[i]) && !strings.ContainsRune(skip, char) {
+			return &InvalidCharacterError{Char: char, Index: i, Charset: l.charset}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package library
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+/*
+	TESTING normalization of text before a search
+*/
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]struct {
+		text string
+		want string
+	}{
+		"valid":       {"hello world", "hello world"},
+		"case":        {"Hello World", "hello world"},
+		"diacritics":  {"café crème, naïve", "cafe creme, naive"},
+		"ligatures":   {"straße œuvre", "strasse oeuvre"},
+		"whitespace":  {"hello\tworld\r\nagain\n", "hello world again "},
+		"digits":      {"room 42", "room four two"},
+		"symbols":     {"salt & pepper, 5%", "salt and pepper, five percent"},
+		"glued":       {"a&b", "a and b"},
+		"punctuation": {"what? no! wait; now: go", "what. no. wait, now, go"},
+		"dropped":     {"\"quoted\" (text)", "quoted text"},
+	}
+	library := newTestLibrary(t)
+	for name, test := range tests {
+		got, _, err := library.Normalize(test.text)
+		if err != nil {
+			t.Fatalf("%s: failed to normalize: %v", name, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", name, got, test.want)
+		}
+		if err := library.validateText(got); err != nil {
+			t.Errorf("%s: normalized text is not valid: %v", name, err)
+		}
+	}
+}
+
+func TestNormalizeReportsChanges(t *testing.T) {
+	library := newTestLibrary(t)
+	_, changes, err := library.Normalize("Éa\r\n4!")
+	if err != nil {
+		t.Fatalf("failed to normalize: %v", err)
+	}
+	want := []Change{
+		{Index: 0, From: "É", To: "e"},
+		{Index: 2, From: "\r\n", To: " "},
+		{Index: 4, From: "4", To: "four"},
+		{Index: 5, From: "!", To: "."},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+
+	if _, changes, _ := library.Normalize(searchText); len(changes) != 0 {
+		t.Errorf("got changes %v to valid text, want none", changes)
+	}
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	library := newTestLibrary(t)
+	got, _, err := library.Normalize("1 + ü?", WithMapping(map[rune]string{'ü': "ue", '+': "and"}), WithKept("?"))
+	if err != nil {
+		t.Fatalf("failed to normalize: %v", err)
+	}
+	if want := "one and ue?"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// mapped values outside the charset are rejected, kept characters aside
+	_, _, err = library.Normalize("ü", WithMapping(map[rune]string{'ü': "u\u0308"}))
+	var invalid *InvalidCharacterError
+	if !errors.As(err, &invalid) || invalid.Char != '\u0308' {
+		t.Errorf("got %v, want an InvalidCharacterError for the combining diaeresis", err)
+	}
+	if _, _, err := library.Normalize("ü", WithMapping(map[rune]string{'ü': "u*"}), WithKept("*")); err != nil {
+		t.Errorf("got %v, want a kept character accepted in a mapping", err)
+	}
+
+	// charsets writing digits and both cases keep them
+	alphanumeric := newTestLibrary(t, WithCharset(CharsetAlphanumeric))
+	if got, _, _ := alphanumeric.Normalize("Room 42"); got != "Room 42" {
+		t.Errorf("got %q, want %q", got, "Room 42")
+	}
+	// and charsets without latin letters cannot spell digits out
+	if got, _, _ := newTestLibrary(t, WithCharset(CharsetGreek)).Normalize("ά 4"); got != "α " {
+		t.Errorf("got %q, want %q", got, "α ")
+	}
+}
+
+func TestNormalizeStrict(t *testing.T) {
+	library := newTestLibrary(t)
+	if got, changes, err := library.Normalize("Hello world", WithStrict()); err != nil || got != "Hello world" || len(changes) != 0 {
+		t.Errorf("got %q, %v and err %v, want the text unchanged", got, changes, err)
+	}
+
+	_, _, err := library.Normalize("héllo wörld", WithStrict())
+	var invalid *InvalidCharacterError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want an InvalidCharacterError", err)
+	}
+	if invalid.Char != 'é' || invalid.Index != 1 {
+		t.Errorf("got %q at index %d, want 'é' at index 1", invalid.Char, invalid.Index)
+	}
+
+	// kept characters such as wildcards and line breaks pass
+	if _, _, err := library.Normalize("the c?t\nsat*", WithStrict(), WithKept("?*\n")); err != nil {
+		t.Errorf("got %v, want kept characters accepted", err)
+	}
+
+	// searches report the same error
+	_, err = library.SearchPaginated("hello wörld", 0, 1)
+	if !errors.As(err, &invalid) || invalid.Char != 'ö' || invalid.Index != 7 {
+		t.Errorf("got %v, want 'ö' at index 7", err)
+	}
+}
@@ -0,0 +1,181 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// wildcard standing for any single character
+	wildcardChar = '?'
+	// wildcard standing for a run of any characters, possibly none
+	wildcardRun = '*'
+	// longest run of characters a single run wildcard expands to
+	wildcardRunLimit = 16
+	// passes over a page rewriting excluded text before giving up
+	exclusionPasses = 64
+)
+
+// WithWildcards reads ? in the searched text as any single character and * as a run of up
+// to 16 characters, both filled at random per variant
+func WithWildcards() SearchOption {
+	return func(c *searchConfig) {
+		c.wildcards = true
+	}
+}
+
+// WithExcluded keeps texts off the page, rewriting any of them the filler or wildcards
+// happen to spell. Rewritten characters are drawn at random whatever the fill.
+func WithExcluded(texts ...string) SearchOption {
+	return func(c *searchConfig) {
+		c.excluded = append(c.excluded, texts...)
+	}
+}
+
+// textLength is the number of characters text takes on a page, the fewest a pattern can
+// expand to when it holds wildcards
+func (c searchConfig) textLength(text string) int {
+	text = c.withoutLineBreaks(text)
+	if !c.wildcards {
+		return utf8.RuneCountInString(text)
+	}
+	return utf8.RuneCountInString(text) - strings.Count(text, string(wildcardRun))
+}
+
+// withoutLineBreaks drops the line breaks of block text, which take no cell on the page
+func (c searchConfig) withoutLineBreaks(text string) string {
+	if c.layout != LayoutBlock {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", "")
+}
+
+// singleWildcards is the number of characters in text left to chance
+func (c searchConfig) singleWildcards(text string) int {
+	if !c.wildcards {
+		return 0
+	}
+	return strings.Count(text, string(wildcardChar))
+}
+
+// validatePattern is validateText for text holding wildcards
+func (l Library) validatePattern(text string) error {
+	if text == "" {
+		return errors.New("text should not be empty")
+	}
+	length := searchConfig{wildcards: true}.textLength(text)
+	if charsPerPage := l.geometry.CharsPerPage(); length > charsPerPage {
+		return fmt.Errorf("text exceeds %d character limit", charsPerPage)
+	}
+	return l.checkCharset(text, string(wildcardChar)+string(wildcardRun))
+}
+
+// validateExcluded checks the excluded texts can be spelled and none of them is part of
+// the literal text, where it could never be rewritten
+func (l Library) validateExcluded(text string, config searchConfig) error {
+	// only characters written side by side on a line can spell an excluded text
+	literals := strings.FieldsFunc(l.charset.Fold(text), func(char rune) bool {
+		return config.wildcards && (char == wildcardChar || char == wildcardRun) ||
+			config.layout == LayoutBlock && char == '\n'
+	})
+	if config.layout == LayoutVertical || config.layout == LayoutDiagonal {
+		literals = strings.Split(strings.Join(literals, ""), "")
+	}
+	for _, excluded := range config.excluded {
+		if excluded == "" {
+			return errors.New("excluded text should not be empty")
+		}
+		for _, char := range l.charset.Fold(excluded) {
+			if !l.charset.Contains(char) {
+				return fmt.Errorf("excluded text %q contains invalid characters", excluded)
+			}
+		}
+		for _, literal := range literals {
+			if strings.Contains(literal, l.charset.Fold(excluded)) {
+				return fmt.Errorf("text contains excluded text %q", excluded)
+			}
+		}
+	}
+	return nil
+}
+
+// expandPattern fills the wildcards of pattern from rng, letting run wildcards add no more
+// than room characters between them. Returns the text and which of its characters were
+// written literally.
+func (l Library) expandPattern(rng *rand.Rand, pattern []rune, room int) ([]rune, []bool) {
+	symbols := l.charset.symbols
+	text := make([]rune, 0, len(pattern))
+	literal := make([]bool, 0, len(pattern))
+	for _, char := range pattern {
+		switch char {
+		case wildcardChar:
+			text = append(text, symbols[rng.Intn(len(symbols))])
+			literal = append(literal, false)
+		case wildcardRun:
+			run := rng.Intn(min(wildcardRunLimit, room) + 1)
+			room -= run
+			for range run {
+				text = append(text, symbols[rng.Intn(len(symbols))])
+				literal = append(literal, false)
+			}
+		default:
+			text = append(text, char)
+			literal = append(literal, true)
+		}
+	}
+	return text, literal
+}
+
+// avoidExcluded rewrites the characters of pageChars spelling an excluded text, drawing
+// replacements from rng. Only characters not marked fixed are rewritten.
+func (l Library) avoidExcluded(pageChars []rune, fixed []bool, excluded []string, rng *rand.Rand) error {
+	if len(excluded) == 0 {
+		return nil
+	}
+	words := make([][]rune, len(excluded))
+	for i, text := range excluded {
+		words[i] = []rune(l.charset.Fold(text))
+	}
+
+	for range exclusionPasses {
+		clean := true
+		for w, word := range words {
+			for i := 0; i+len(word) <= len(pageChars); i++ {
+				if !slices.Equal(pageChars[i:i+len(word)], word) {
+					continue
+				}
+				clean = false
+
+				free := []int{}
+				for cell := i; cell < i+len(word); cell++ {
+					if !fixed[cell] {
+						free = append(free, cell)
+					}
+				}
+				if len(free) == 0 {
+					return fmt.Errorf("text spells excluded text %q", excluded[w])
+				}
+				cell := free[rng.Intn(len(free))]
+				pageChars[cell] = l.otherSymbol(pageChars[cell], rng)
+			}
+		}
+		if clean {
+			return nil
+		}
+	}
+	return errors.New("could not keep excluded text off the page")
+}
+
+// otherSymbol draws any symbol of the charset but char
+func (l Library) otherSymbol(char rune, rng *rand.Rand) rune {
+	symbols := l.charset.symbols
+	replacement := symbols[rng.Intn(len(symbols)-1)]
+	if replacement == char {
+		replacement = symbols[len(symbols)-1]
+	}
+	return replacement
+}
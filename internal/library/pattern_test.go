@@ -0,0 +1,125 @@
+package library
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING wildcard patterns and excluded text
+*/
+
+func TestSearchWithWildcards(t *testing.T) {
+	library := newTestLibrary(t)
+	tests := map[string]*regexp.Regexp{
+		"the c?t sat":   regexp.MustCompile(`^the c.t sat$`),
+		"hello * world": regexp.MustCompile(`^hello .{0,16} world$`),
+		"?*?":           regexp.MustCompile(`^.{2,18}$`),
+	}
+	for pattern, re := range tests {
+		results, err := library.SearchPaginated(pattern, 0, 20, WithWildcards())
+		if err != nil {
+			t.Fatalf("%q: search failed: %v", pattern, err)
+		}
+
+		expansions := map[string]bool{}
+		for _, result := range results {
+			page, _ := library.Browse(result.Location)
+			written := page[result.Offset : result.Offset+result.Length]
+			if !re.MatchString(written) {
+				t.Errorf("%q: page holds %q", pattern, written)
+			}
+			if result.Snippet.Match != written {
+				t.Errorf("%q: snippet holds %q, page holds %q", pattern, result.Snippet.Match, written)
+			}
+			expansions[written] = true
+		}
+		if len(expansions) < 2 {
+			t.Errorf("%q: expected wildcards to be filled differently between variants", pattern)
+		}
+	}
+
+	if _, err := library.SearchPaginated("the c?t", 0, 1); err == nil {
+		t.Errorf("got nil, expected err for wildcards without WithWildcards")
+	}
+}
+
+func TestSearchWithWildcardsFitsThePage(t *testing.T) {
+	library := newTestLibrary(t)
+	// the run wildcard has no room left when the text fills the line it is pinned to
+	pattern := strings.Repeat("a", 40) + "*"
+	results, err := library.SearchPaginated(pattern, 0, 20, WithWildcards(), WithPosition(40, 41))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Length != 40 {
+			t.Errorf("expected the run wildcard to stay empty, got %d characters", result.Length)
+		}
+	}
+}
+
+func TestReachableCountWithWildcards(t *testing.T) {
+	library := newTestLibrary(t)
+	// one free character after the text and one wildcard within it
+	pattern := strings.Repeat("a", 3197) + "?a"
+	if got := library.ReachableCount(pattern, WithWildcards(), WithPosition(1, 1)); got != 29*29 {
+		t.Errorf("got %d, want %d", got, 29*29)
+	}
+}
+
+func TestSearchWithExcluded(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated("hi", 0, 5, WithExcluded("e", "th"))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		page, _ := library.Browse(result.Location)
+		if strings.Contains(page, "e") || strings.Contains(page, "th") {
+			t.Errorf("page at %s holds excluded text", result.Location)
+		}
+		if page[result.Offset:result.Offset+result.Length] != "hi" {
+			t.Errorf("expected the text kept intact")
+		}
+	}
+
+	// wildcards are rewritten like the filler
+	results, err = library.SearchPaginated("h??", 0, 5,
+		WithWildcards(), WithExcluded("ha", "hb"), WithFill(FillBlank))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		page, _ := library.Browse(result.Location)
+		if strings.Contains(page, "ha") || strings.Contains(page, "hb") {
+			t.Errorf("page at %s holds excluded text", result.Location)
+		}
+	}
+
+	query := Query{Phrases: []Phrase{{Text: "alice"}, {Text: "bob"}}}
+	queryResults, err := library.SearchQueryPaginated(query, 0, 5, WithExcluded("x"))
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	for _, result := range queryResults {
+		page, _ := library.Browse(result.Location)
+		if strings.Contains(page, "x") {
+			t.Errorf("page at %s holds excluded text", result.Location)
+		}
+	}
+}
+
+func TestSearchWithExcludedInText(t *testing.T) {
+	library := newTestLibrary(t)
+	if _, err := library.SearchPaginated(searchText, 0, 1, WithExcluded("lo w")); err == nil {
+		t.Errorf("got nil, expected err for text holding excluded text")
+	}
+	if _, err := library.SearchPaginated(searchText, 0, 1, WithExcluded("")); err == nil {
+		t.Errorf("got nil, expected err for empty excluded text")
+	}
+	if _, err := library.SearchPaginated(searchText, 0, 1, WithExcluded("!")); err == nil {
+		t.Errorf("got nil, expected err for excluded text outside the charset")
+	}
+}
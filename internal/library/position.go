@@ -0,0 +1,128 @@
+package library
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+)
+
+type positionKind int
+
+const (
+	// anywhere on the page, the default
+	positionAnywhere positionKind = iota
+	// at a fixed line and column
+	positionExact
+	// at the start of a line
+	positionLineStart
+	// centred on the lines the text spans
+	positionCentred
+)
+
+// position is where on a page a search places its text
+type position struct {
+	kind   positionKind
+	line   int
+	column int
+}
+
+// WithPosition pins the text to start at line and column, both counting from 1
+func WithPosition(line, column int) SearchOption {
+	return func(c *searchConfig) {
+		c.position = position{kind: positionExact, line: line, column: column}
+	}
+}
+
+// WithLineStart places the text at the start of a line, the line varying between variants
+func WithLineStart() SearchOption {
+	return func(c *searchConfig) {
+		c.position = position{kind: positionLineStart}
+	}
+}
+
+// WithCentred centres the text on the lines it spans, the lines varying between variants.
+// Text no longer than a line is centred on a single line.
+func WithCentred() SearchOption {
+	return func(c *searchConfig) {
+		c.position = position{kind: positionCentred}
+	}
+}
+
+// validate checks text of the given length fits on a page at the position
+func (p position) validate(length int, geometry Geometry) error {
+	if p.kind != positionExact {
+		return nil
+	}
+	if p.line < 1 || p.line > geometry.LinesPerPage {
+		return fmt.Errorf("line must be between 1 and %d, got %d", geometry.LinesPerPage, p.line)
+	}
+	if p.column < 1 || p.column > geometry.CharsPerLine {
+		return fmt.Errorf("column must be between 1 and %d, got %d", geometry.CharsPerLine, p.column)
+	}
+	if p.offset(0, length, geometry)+length > geometry.CharsPerPage() {
+		return fmt.Errorf("text of %d characters runs off the page from line %d, column %d",
+			length, p.line, p.column)
+	}
+	return nil
+}
+
+// candidates is the number of offsets text of the given length can start at
+func (p position) candidates(length int, geometry Geometry) int {
+	switch p.kind {
+	case positionExact:
+		return 1
+	case positionLineStart:
+		return (geometry.CharsPerPage()-length)/geometry.CharsPerLine + 1
+	case positionCentred:
+		return geometry.LinesPerPage - p.linesSpanned(length, geometry) + 1
+	default:
+		return geometry.CharsPerPage() - length + 1
+	}
+}
+
+// offset is the page offset of the candidate'th place text of the given length can start at
+func (p position) offset(candidate, length int, geometry Geometry) int {
+	charsPerLine := geometry.CharsPerLine
+	switch p.kind {
+	case positionExact:
+		return (p.line-1)*charsPerLine + p.column - 1
+	case positionLineStart:
+		return candidate * charsPerLine
+	case positionCentred:
+		padding := (p.linesSpanned(length, geometry)*charsPerLine - length) / 2
+		return candidate*charsPerLine + padding
+	default:
+		return candidate
+	}
+}
+
+// room is the number of characters text of the given length can grow by and still fit
+func (p position) room(length int, geometry Geometry) int {
+	room := geometry.CharsPerPage() - length
+	if p.kind == positionExact {
+		room -= p.offset(0, length, geometry)
+	}
+	return room
+}
+
+// pick chooses the offset of a variant's text from the variant's random source
+func (p position) pick(rng *rand.Rand, length int, geometry Geometry) int {
+	if p.kind == positionExact {
+		return p.offset(0, length, geometry)
+	}
+	return p.offset(rng.Intn(p.candidates(length, geometry)), length, geometry)
+}
+
+func (p position) linesSpanned(length int, geometry Geometry) int {
+	return max(1, (length+geometry.CharsPerLine-1)/geometry.CharsPerLine)
+}
+
+// count is the number of pages holding text of the given length at one of the position's
+// offsets, each offset leaving the rest of the page free. A page holding the text at two
+// offsets counts twice, which can only happen when the text is short enough to leave far
+// more pages than a search can reach through variants.
+func (p position) count(length int, geometry Geometry, base *big.Int) *big.Int {
+	free := big.NewInt(int64(geometry.CharsPerPage() - length))
+	count := new(big.Int).Exp(base, free, nil)
+	return count.Mul(count, big.NewInt(int64(p.candidates(length, geometry))))
+}
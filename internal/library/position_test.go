@@ -0,0 +1,115 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING positional search
+*/
+
+func TestSearchWithPosition(t *testing.T) {
+	library := newTestLibrary(t)
+	for _, pin := range [][2]int{{1, 1}, {3, 10}, {40, 70}} {
+		results, err := library.SearchPaginated(searchText, 0, 5, WithPosition(pin[0], pin[1]))
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if err := assertSearchResults(library, results); err != nil {
+			t.Errorf("results assertion failed: %v", err)
+		}
+		for _, result := range results {
+			if result.Line != pin[0] || result.Column != pin[1] {
+				t.Errorf("got line %d, column %d, want line %d, column %d",
+					result.Line, result.Column, pin[0], pin[1])
+			}
+		}
+		if results[0].Location.Equals(*results[1].Location) {
+			t.Errorf("variants pinned to the same position should land on different pages")
+		}
+	}
+}
+
+func TestSearchWithLineStart(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated(searchText, 0, 20, WithLineStart())
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+
+	lines := map[int]bool{}
+	for _, result := range results {
+		if result.Column != 1 {
+			t.Errorf("got column %d, want 1", result.Column)
+		}
+		lines[result.Line] = true
+	}
+	if len(lines) < 2 {
+		t.Errorf("expected the line to vary between variants")
+	}
+}
+
+func TestSearchWithCentred(t *testing.T) {
+	library := newTestLibrary(t)
+	long := strings.Repeat("abcdefghij", 10)
+	tests := map[string]int{
+		// (80 - 11) / 2 characters either side, rounding down on the left
+		searchText: 35,
+		// spans 2 lines, leaving (160 - 100) / 2 characters either side
+		long: 31,
+	}
+	for text, column := range tests {
+		results, err := library.SearchPaginated(text, 0, 5, WithCentred())
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if err := assertSearchResultsFor(library, text, results); err != nil {
+			t.Errorf("results assertion failed: %v", err)
+		}
+		for _, result := range results {
+			if result.Column != column {
+				t.Errorf("%d characters: got column %d, want %d", len(text), result.Column, column)
+			}
+		}
+	}
+}
+
+func TestSearchWithInvalidPosition(t *testing.T) {
+	library := newTestLibrary(t)
+	for _, pin := range [][2]int{{0, 1}, {41, 1}, {1, 0}, {1, 81}, {40, 75}} {
+		if _, err := library.SearchPaginated(searchText, 0, 1, WithPosition(pin[0], pin[1])); err == nil {
+			t.Errorf("line %d, column %d: got nil, expected err", pin[0], pin[1])
+		}
+		if got := library.ReachableCount(searchText, WithPosition(pin[0], pin[1])); got != 0 {
+			t.Errorf("line %d, column %d: got %d reachable pages, want 0", pin[0], pin[1], got)
+		}
+	}
+}
+
+func TestReachableCountWithPosition(t *testing.T) {
+	library := newTestLibrary(t)
+	text := strings.Repeat("a", 3199)
+	// a single free character after the text
+	if got := library.ReachableCount(text, WithPosition(1, 1)); got != 29 {
+		t.Errorf("got %d, want 29", got)
+	}
+	// only the first line starts early enough to fit the text
+	if got := library.ReachableCount(text, WithLineStart()); got != 29 {
+		t.Errorf("got %d, want 29", got)
+	}
+	if got := library.ReachableCount(searchText, WithPosition(1, 1)); got != maxReachableVariants {
+		t.Errorf("got %d, want %d", got, maxReachableVariants)
+	}
+
+	results, err := library.SearchPaginated(text, 0, 100, WithPosition(1, 1))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 29 {
+		t.Errorf("got %d results, want 29", len(results))
+	}
+}
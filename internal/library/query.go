@@ -0,0 +1,687 @@
+package library
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"slices"
+)
+
+// maximum number of phrases in a query, counting the layouts of unordered phrases takes
+// time exponential in it
+const maxQueryPhrases = 8
+
+// attempts at spreading unordered phrases over the gaps between pinned phrases at random
+// before falling back to the packing found when the query was planned
+const queryPackingAttempts = 8
+
+// Phrase is one of the texts a query writes on a page
+type Phrase struct {
+	Text string
+	// line and column of the phrase's first character, counting from 1, both zero to
+	// let the phrase sit anywhere
+	Line   int
+	Column int
+}
+
+// Query asks for pages holding several phrases at once, none overlapping another
+type Query struct {
+	Phrases []Phrase
+	// Ordered keeps the phrases in the order given, from the top of the page down
+	Ordered bool
+}
+
+// QueryResult is a page holding every phrase of a query and where each phrase sits
+type QueryResult struct {
+	Location *Location
+	// index of the variant the page was generated from
+	Variant int
+	// where each phrase sits on the page, in the order of the query's phrases
+	Matches  []Match
+	Snippets []Snippet
+}
+
+// queryPlan is a validated query, with the free stretches of page its unpinned phrases
+// can be written in
+type queryPlan struct {
+	query   Query
+	phrases [][]rune
+	// offset of each pinned phrase, -1 for phrases free to sit anywhere
+	pinned []int
+	// free stretches of page between pinned phrases, from the top of the page down
+	gaps []gap
+	// gap each free phrase goes into, fixed by the order in ordered queries and the
+	// fallback packing in unordered ones
+	gapOf map[int]int
+	// total length of the phrases
+	length int
+}
+
+// gap is the stretch of page [start, end)
+type gap struct {
+	start int
+	end   int
+}
+
+func (g gap) size() int {
+	return g.end - g.start
+}
+
+// planQuery checks every phrase of query fits on a page alongside the others
+func (l Library) planQuery(query Query) (*queryPlan, error) {
+	if len(query.Phrases) == 0 {
+		return nil, errors.New("query should hold at least one phrase")
+	}
+	if len(query.Phrases) > maxQueryPhrases {
+		return nil, fmt.Errorf("query holds %d phrases, at most %d are supported",
+			len(query.Phrases), maxQueryPhrases)
+	}
+
+	plan := &queryPlan{query: query, gapOf: map[int]int{}}
+	pinned := []int{}
+	for i, phrase := range query.Phrases {
+		if err := l.validateText(phrase.Text); err != nil {
+			return nil, fmt.Errorf("phrase %d: %w", i+1, err)
+		}
+		runes := []rune(l.charset.Fold(phrase.Text))
+		plan.phrases = append(plan.phrases, runes)
+		plan.length += len(runes)
+		plan.pinned = append(plan.pinned, -1)
+
+		if phrase.Line == 0 && phrase.Column == 0 {
+			continue
+		}
+		pin := position{kind: positionExact, line: phrase.Line, column: phrase.Column}
+		if err := pin.validate(len(runes), l.geometry); err != nil {
+			return nil, fmt.Errorf("phrase %d: %w", i+1, err)
+		}
+		plan.pinned[i] = pin.offset(0, len(runes), l.geometry)
+		pinned = append(pinned, i)
+	}
+	if charsPerPage := l.geometry.CharsPerPage(); plan.length > charsPerPage {
+		return nil, fmt.Errorf("phrases exceed %d character limit together", charsPerPage)
+	}
+
+	if query.Ordered {
+		for i := 1; i < len(pinned); i++ {
+			if plan.pinned[pinned[i]] < plan.pinned[pinned[i-1]] {
+				return nil, fmt.Errorf("phrase %d is pinned above phrase %d in an ordered query",
+					pinned[i]+1, pinned[i-1]+1)
+			}
+		}
+	}
+	slices.SortFunc(pinned, func(a, b int) int {
+		return plan.pinned[a] - plan.pinned[b]
+	})
+
+	start := 0
+	for i, phrase := range pinned {
+		if plan.pinned[phrase] < start {
+			return nil, fmt.Errorf("phrases %d and %d overlap", pinned[i-1]+1, phrase+1)
+		}
+		plan.gaps = append(plan.gaps, gap{start: start, end: plan.pinned[phrase]})
+		start = plan.pinned[phrase] + len(plan.phrases[phrase])
+	}
+	plan.gaps = append(plan.gaps, gap{start: start, end: l.geometry.CharsPerPage()})
+
+	if query.Ordered {
+		return plan, plan.assignInOrder()
+	}
+	return plan, plan.pack()
+}
+
+// assignInOrder puts each free phrase of an ordered query into the gap between the
+// pinned phrases either side of it
+func (p *queryPlan) assignInOrder() error {
+	gapIndex := 0
+	used := make([]int, len(p.gaps))
+	for i, offset := range p.pinned {
+		if offset >= 0 {
+			gapIndex++
+			continue
+		}
+		p.gapOf[i] = gapIndex
+		used[gapIndex] += len(p.phrases[i])
+		if used[gapIndex] > p.gaps[gapIndex].size() {
+			return fmt.Errorf("phrase %d does not fit between the pinned phrases around it", i+1)
+		}
+	}
+	return nil
+}
+
+// pack finds a gap for each free phrase of an unordered query. Like layouts, it goes
+// through the gaps in turn trying every subset of the phrases left in each, so it finds
+// a packing whenever one exists.
+func (p *queryPlan) pack() error {
+	free := p.freePhrases()
+	full := 1<<len(free) - 1
+	// total length of the free phrases in each subset
+	lengths := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		lowest := bits.TrailingZeros(uint(mask))
+		lengths[mask] = lengths[mask&(mask-1)] + len(p.phrases[free[lowest]])
+	}
+
+	// written[g][mask] is the subset of mask written into gap g-1 when the first g gaps
+	// hold exactly the phrases of mask, -1 when they cannot
+	written := make([][]int, len(p.gaps)+1)
+	for g := range written {
+		written[g] = slices.Repeat([]int{-1}, full+1)
+	}
+	written[0][0] = 0
+	for g, gap := range p.gaps {
+		for mask, subset := range written[g] {
+			if subset < 0 {
+				continue
+			}
+			rest := full &^ mask
+			// every subset of the remaining phrases, the empty one included
+			for subset := rest; ; subset = (subset - 1) & rest {
+				if lengths[subset] <= gap.size() && written[g+1][mask|subset] < 0 {
+					written[g+1][mask|subset] = subset
+				}
+				if subset == 0 {
+					break
+				}
+			}
+		}
+	}
+	if written[len(p.gaps)][full] < 0 {
+		return errors.New("cannot find room for the free phrases around the pinned phrases")
+	}
+
+	// walk back from the last gap to read off which phrases went where
+	mask := full
+	for g := len(p.gaps) - 1; g >= 0; g-- {
+		subset := written[g+1][mask]
+		for i, phrase := range free {
+			if subset&(1<<i) != 0 {
+				p.gapOf[phrase] = g
+			}
+		}
+		mask &^= subset
+	}
+	return nil
+}
+
+func (p *queryPlan) freePhrases() []int {
+	free := []int{}
+	for i, offset := range p.pinned {
+		if offset < 0 {
+			free = append(free, i)
+		}
+	}
+	return free
+}
+
+// layout draws the offset of every phrase from rng
+func (p *queryPlan) layout(rng *rand.Rand) []int {
+	offsets := slices.Clone(p.pinned)
+	free := p.freePhrases()
+	gapOf := p.gapOf
+	if !p.query.Ordered {
+		rng.Shuffle(len(free), func(i, j int) {
+			free[i], free[j] = free[j], free[i]
+		})
+		if spread, ok := p.spread(rng, free); ok {
+			gapOf = spread
+		}
+	}
+
+	// within a gap, the phrases keep their order and the slack is split between them at random
+	for g, gap := range p.gaps {
+		inGap := []int{}
+		slack := gap.size()
+		for _, phrase := range free {
+			if gapOf[phrase] == g {
+				inGap = append(inGap, phrase)
+				slack -= len(p.phrases[phrase])
+			}
+		}
+		cuts := make([]int, len(inGap))
+		for i := range cuts {
+			cuts[i] = rng.Intn(slack + 1)
+		}
+		slices.Sort(cuts)
+
+		start := gap.start
+		for i, phrase := range inGap {
+			offsets[phrase] = start + cuts[i]
+			start += len(p.phrases[phrase])
+		}
+	}
+	return offsets
+}
+
+// layoutAt is the index'th layout of the phrases, counting the layouts as layouts does, so
+// blank pages, which only differ in layout, walk through them in turn
+func (p *queryPlan) layoutAt(index int) []int {
+	offsets := slices.Clone(p.pinned)
+	free := p.freePhrases()
+	rest := big.NewInt(int64(index))
+	// writes phrases into the gap in the order given, the arrangement'th way of splitting its slack
+	write := func(gap gap, phrases []int, arrangement *big.Int) {
+		slack := gap.size()
+		for _, phrase := range phrases {
+			slack -= len(p.phrases[phrase])
+		}
+		start := gap.start
+		for i, cut := range cutsAt(arrangement, len(phrases), slack) {
+			offsets[phrases[i]] = start + cut
+			start += len(p.phrases[phrases[i]])
+		}
+	}
+
+	if p.query.Ordered {
+		for g, gap := range p.gaps {
+			inGap := []int{}
+			slack := gap.size()
+			for _, phrase := range free {
+				if p.gapOf[phrase] == g {
+					inGap = append(inGap, phrase)
+					slack -= len(p.phrases[phrase])
+				}
+			}
+			arrangement := new(big.Int)
+			rest.DivMod(rest, inOrder(len(inGap), slack), arrangement)
+			write(gap, inGap, arrangement)
+		}
+		return offsets
+	}
+
+	// from the last gap back, find the subset of phrases written in it, then their order and
+	// where they sit, what is left of the index laying out the earlier gaps
+	ways := p.unorderedLayouts()
+	mask := 1<<len(free) - 1
+	for g := len(p.gaps) - 1; g >= 0; g-- {
+		for subset := mask; ; subset = (subset - 1) & mask {
+			term := p.gapLayouts(free, subset, p.gaps[g])
+			within := big.NewInt(0)
+			if before := ways[g][mask&^subset]; before != nil {
+				within.Mul(before, term)
+			}
+			if rest.Cmp(within) < 0 {
+				inGap := []int{}
+				for i, phrase := range free {
+					if subset&(1<<i) != 0 {
+						inGap = append(inGap, phrase)
+					}
+				}
+				arrangement, order := new(big.Int), new(big.Int)
+				rest.DivMod(rest, term, arrangement)
+				orders := new(big.Int).MulRange(1, int64(max(len(inGap), 1)))
+				arrangement.DivMod(arrangement, orders, order)
+				write(p.gaps[g], permutationAt(inGap, order), arrangement)
+				mask &^= subset
+				break
+			}
+			rest.Sub(rest, within)
+			if subset == 0 {
+				break
+			}
+		}
+	}
+	return offsets
+}
+
+// cutsAt is the index'th way of splitting slack free characters between n phrases in a
+// gap, as the number of free characters before each phrase, counting from the lowest
+func cutsAt(index *big.Int, n, slack int) []int {
+	index = new(big.Int).Set(index)
+	cuts := make([]int, n)
+	low := 0
+	for i := range cuts {
+		for cut := low; cut <= slack; cut++ {
+			// the ways of placing the phrases after this one, none before it
+			after := inOrder(n-i-1, slack-cut)
+			if index.Cmp(after) < 0 {
+				cuts[i], low = cut, cut
+				break
+			}
+			index.Sub(index, after)
+		}
+	}
+	return cuts
+}
+
+// permutationAt is the index'th ordering of items, orderings counted in lexicographic
+// order of the items' positions
+func permutationAt(items []int, index *big.Int) []int {
+	items = slices.Clone(items)
+	rest := new(big.Int).Set(index)
+	ordered := make([]int, 0, len(items))
+	for len(items) > 0 {
+		position := new(big.Int)
+		position.DivMod(rest, new(big.Int).MulRange(1, int64(len(items)-1)), rest)
+		i := int(position.Int64())
+		ordered = append(ordered, items[i])
+		items = slices.Delete(items, i, i+1)
+	}
+	return ordered
+}
+
+// spread puts each free phrase into a random gap with room for it
+func (p *queryPlan) spread(rng *rand.Rand, free []int) (map[int]int, bool) {
+	for range queryPackingAttempts {
+		gapOf := map[int]int{}
+		room := make([]int, len(p.gaps))
+		for i, gap := range p.gaps {
+			room[i] = gap.size()
+		}
+
+		ok := true
+		for _, phrase := range free {
+			fitting := []int{}
+			for i := range room {
+				if room[i] >= len(p.phrases[phrase]) {
+					fitting = append(fitting, i)
+				}
+			}
+			if len(fitting) == 0 {
+				ok = false
+				break
+			}
+			chosen := fitting[rng.Intn(len(fitting))]
+			gapOf[phrase] = chosen
+			room[chosen] -= len(p.phrases[phrase])
+		}
+		if ok {
+			return gapOf, true
+		}
+	}
+	return nil, false
+}
+
+// layouts is the number of ways the phrases can be laid out on a page
+func (p *queryPlan) layouts() *big.Int {
+	free := p.freePhrases()
+	if p.query.Ordered {
+		count := big.NewInt(1)
+		for g, gap := range p.gaps {
+			n, slack := 0, gap.size()
+			for _, phrase := range free {
+				if p.gapOf[phrase] == g {
+					n++
+					slack -= len(p.phrases[phrase])
+				}
+			}
+			count.Mul(count, inOrder(n, slack))
+		}
+		return count
+	}
+
+	ways := p.unorderedLayouts()[len(p.gaps)]
+	if count := ways[len(ways)-1]; count != nil {
+		return count
+	}
+	return big.NewInt(0)
+}
+
+// unorderedLayouts counts the layouts of an unordered query's free phrases gap by gap.
+// Phrases can go into any gap in any order, and ways[g][mask] counts the layouts of the
+// free phrases in mask over the first g gaps, nil when they do not fit.
+func (p *queryPlan) unorderedLayouts() [][]*big.Int {
+	free := p.freePhrases()
+	full := 1<<len(free) - 1
+	ways := make([][]*big.Int, len(p.gaps)+1)
+	ways[0] = make([]*big.Int, full+1)
+	ways[0][0] = big.NewInt(1)
+	for g, gap := range p.gaps {
+		next := make([]*big.Int, full+1)
+		for mask, count := range ways[g] {
+			if count == nil {
+				continue
+			}
+			rest := full &^ mask
+			// every subset of the remaining phrases, the empty one included
+			for subset := rest; ; subset = (subset - 1) & rest {
+				if term := p.gapLayouts(free, subset, gap); term.Sign() > 0 {
+					if next[mask|subset] == nil {
+						next[mask|subset] = new(big.Int)
+					}
+					next[mask|subset].Add(next[mask|subset], term.Mul(term, count))
+				}
+				if subset == 0 {
+					break
+				}
+			}
+		}
+		ways[g+1] = next
+	}
+	return ways
+}
+
+// gapLayouts is the number of ways of writing the free phrases in subset into gap, in any
+// order, 0 when they do not fit
+func (p *queryPlan) gapLayouts(free []int, subset int, gap gap) *big.Int {
+	length := 0
+	for i, phrase := range free {
+		if subset&(1<<i) != 0 {
+			length += len(p.phrases[phrase])
+		}
+	}
+	if length > gap.size() {
+		return big.NewInt(0)
+	}
+	n := bits.OnesCount(uint(subset))
+	count := inOrder(n, gap.size()-length)
+	return count.Mul(count, new(big.Int).MulRange(1, int64(max(n, 1))))
+}
+
+// inOrder is the number of ways of writing n phrases in a given order into a gap leaving
+// slack characters free
+func inOrder(n, slack int) *big.Int {
+	return new(big.Int).Binomial(int64(slack+n), int64(n))
+}
+
+// GetQueryCount is the number of pages holding every phrase of query laid out as it asks,
+// counting a page once for each layout of the phrases it holds. Phrases are rarely found on
+// a page in more than one layout, so this is close to the number of distinct pages.
+func (l Library) GetQueryCount(query Query) (*big.Int, error) {
+	plan, err := l.planQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return l.queryCount(plan, FillRandom), nil
+}
+
+// ReachableQueryCount is ReachableCount for a query, the number of results a query search
+// with the same options yields
+func (l Library) ReachableQueryCount(query Query, opts ...SearchOption) int {
+	config, plan, err := l.prepareQuery(query, opts)
+	if err != nil {
+		return 0
+	}
+	return l.reachableQueryCount(plan, config)
+}
+
+func (l Library) reachableQueryCount(plan *queryPlan, config searchConfig) int {
+	count := l.queryCount(plan, config.fill)
+	if count.Cmp(big.NewInt(maxReachableVariants)) > 0 {
+		return maxReachableVariants
+	}
+	return int(count.Int64())
+}
+
+// queryCount is GetQueryCount for pages written in fill, blank pages differing only in layout
+func (l Library) queryCount(plan *queryPlan, fill Fill) *big.Int {
+	count := plan.layouts()
+	if fill != FillBlank {
+		free := big.NewInt(int64(l.geometry.CharsPerPage() - plan.length))
+		count.Mul(count, new(big.Int).Exp(l.base, free, nil))
+	}
+	return count
+}
+
+// validateQueryConfig checks the search options apply to queries, which pin each phrase
+// on their own
+func (l Library) validateQueryConfig(config searchConfig) error {
+	if config.position.kind != positionAnywhere {
+		return errors.New("position options do not apply to queries, pin their phrases instead")
+	}
+	if config.wildcards {
+		return errors.New("wildcards do not apply to queries")
+	}
+	if config.layout != LayoutRun {
+		return errors.New("layouts do not apply to queries")
+	}
+	if config.wordWrap {
+		return errors.New("word wrap does not apply to queries")
+	}
+	if config.shelving.pinned() {
+		return errors.New("shelving options do not apply to queries")
+	}
+	if config.shortest {
+		return errors.New("short addresses do not apply to queries")
+	}
+	if config.prefix != "" {
+		return errors.New("hexagon prefixes do not apply to queries")
+	}
+	if config.fill == FillEnglish && len(l.words) == 0 {
+		return fmt.Errorf("charset %s cannot spell English filler", l.charset.Name())
+	}
+	return nil
+}
+
+// SearchQueryStream is SearchStream for a query, yielding pages holding all of its phrases.
+// Options placing text at a position are rejected, phrases carry their own positions.
+// With FillBlank pages only differ in layout, so variants walk through the layouts in turn.
+func (l Library) SearchQueryStream(
+	ctx context.Context,
+	query Query,
+	opts ...SearchOption,
+) (<-chan *QueryResult, <-chan error) {
+	config, plan, err := l.prepareQuery(query, opts)
+	if err != nil {
+		resultChan, errChan := make(chan *QueryResult), make(chan error, 1)
+		errChan <- err
+		close(errChan)
+		close(resultChan)
+		return resultChan, errChan
+	}
+
+	totalCount := l.reachableQueryCount(plan, config)
+	if config.maxResults > 0 {
+		totalCount = min(totalCount, config.maxResults)
+	}
+
+	return generateVariants(ctx, 0, totalCount, config.workers, func(variant int) (*QueryResult, error) {
+		return l.queryResult(plan, variant, config)
+	})
+}
+
+// SearchQueryPaginated returns the pages of variants offset to offset+limit of query,
+// the same results SearchQueryStream yields at those positions
+func (l Library) SearchQueryPaginated(
+	query Query,
+	offset, limit int,
+	opts ...SearchOption,
+) ([]*QueryResult, error) {
+	config, plan, err := l.prepareQuery(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, errors.New("offset cannot be negative")
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	totalCount := l.reachableQueryCount(plan, config)
+	if config.maxResults > 0 {
+		totalCount = min(totalCount, config.maxResults)
+	}
+	if offset >= totalCount {
+		return []*QueryResult{}, nil
+	}
+	endIndex := min(offset+limit, totalCount)
+
+	results := make([]*QueryResult, 0, endIndex-offset)
+	resultChan, errChan := generateVariants(
+		context.Background(), offset, endIndex, config.workers,
+		func(variant int) (*QueryResult, error) {
+			return l.queryResult(plan, variant, config)
+		},
+	)
+	for result := range resultChan {
+		results = append(results, result)
+	}
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (l Library) prepareQuery(query Query, opts []SearchOption) (searchConfig, *queryPlan, error) {
+	config, err := newSearchConfig(opts)
+	if err != nil {
+		return searchConfig{}, nil, err
+	}
+	if err := l.validateQueryConfig(config); err != nil {
+		return searchConfig{}, nil, err
+	}
+	plan, err := l.planQuery(query)
+	if err != nil {
+		return searchConfig{}, nil, err
+	}
+	for _, phrase := range query.Phrases {
+		if err := l.validateExcluded(phrase.Text, config); err != nil {
+			return searchConfig{}, nil, err
+		}
+	}
+	return config, plan, nil
+}
+
+// queryResult generates the page holding variant of a planned query
+func (l Library) queryResult(plan *queryPlan, variant int, config searchConfig) (*QueryResult, error) {
+	hasher := sha256.New()
+	for i, phrase := range plan.phrases {
+		fmt.Fprintf(hasher, "%s\x00%d\x00", string(phrase), plan.pinned[i])
+	}
+	fmt.Fprintf(hasher, "%t\x00%d", plan.query.Ordered, variant)
+	querySeed := int64(binary.BigEndian.Uint64(hasher.Sum(nil)[:8])) //nolint:gosec // overflow acceptable
+	rng := rand.New(rand.NewSource(querySeed))                       //nolint:gosec // crypto not needed
+
+	var offsets []int
+	if config.fill == FillBlank {
+		offsets = plan.layoutAt(variant)
+	} else {
+		offsets = plan.layout(rng)
+	}
+	segments := make([]segment, len(plan.phrases))
+	for i, phrase := range plan.phrases {
+		segments[i] = segment{offset: offsets[i], text: phrase}
+	}
+	slices.SortFunc(segments, func(a, b segment) int {
+		return a.offset - b.offset
+	})
+
+	pageChars := l.writePage(config.fill, rng, segments)
+	fixed := make([]bool, len(pageChars))
+	for _, segment := range segments {
+		for i := range segment.text {
+			fixed[segment.offset+i] = true
+		}
+	}
+	if err := l.avoidExcluded(pageChars, fixed, config.excluded, rng); err != nil {
+		return nil, err
+	}
+	bigInt, err := l.pageCharsToBase29Number(pageChars)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryResult{Location: l.locate(bigInt), Variant: variant}
+	for i, phrase := range plan.phrases {
+		match := l.newMatch(offsets[i], len(phrase))
+		result.Matches = append(result.Matches, match)
+		result.Snippets = append(result.Snippets, newSnippet(pageChars, match))
+	}
+	return result, nil
+}
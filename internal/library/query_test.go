@@ -0,0 +1,269 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+/*
+	TESTING multi-phrase queries
+*/
+
+func assertQueryResults(library *Library, query Query, results []*QueryResult) error {
+	for _, result := range results {
+		page, err := library.Browse(result.Location)
+		if err != nil {
+			return fmt.Errorf("failed to browse location: %v", err)
+		}
+		for i, phrase := range query.Phrases {
+			match := result.Matches[i]
+			if got := page[match.Offset : match.Offset+match.Length]; got != phrase.Text {
+				return fmt.Errorf("expected %q at offset %d, found %q", phrase.Text, match.Offset, got)
+			}
+			if result.Snippets[i].Match != phrase.Text {
+				return fmt.Errorf("snippet does not hold phrase: %q", result.Snippets[i])
+			}
+			for j, other := range result.Matches[:i] {
+				if match.Offset < other.Offset+other.Length && other.Offset < match.Offset+match.Length {
+					return fmt.Errorf("phrases %d and %d overlap", j+1, i+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestSearchQuery(t *testing.T) {
+	library := newTestLibrary(t)
+	query := Query{Phrases: []Phrase{{Text: "alice"}, {Text: "the first of may"}, {Text: "bob"}}}
+	results, err := library.SearchQueryPaginated(query, 0, 20)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(results) != 20 {
+		t.Errorf("expected 20 results, got %d", len(results))
+	}
+	if err := assertQueryResults(library, query, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+
+	inOrder := 0
+	for _, result := range results {
+		if result.Matches[0].Offset < result.Matches[1].Offset {
+			inOrder++
+		}
+	}
+	if inOrder == 0 || inOrder == len(results) {
+		t.Errorf("expected unordered phrases to come in either order")
+	}
+}
+
+func TestSearchQueryOrdered(t *testing.T) {
+	library := newTestLibrary(t)
+	query := Query{
+		Phrases: []Phrase{{Text: "once"}, {Text: "upon"}, {Text: "a time"}},
+		Ordered: true,
+	}
+	results, err := library.SearchQueryPaginated(query, 0, 20, WithFill(FillEnglish))
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if err := assertQueryResults(library, query, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Matches[0].Offset > result.Matches[1].Offset ||
+			result.Matches[1].Offset > result.Matches[2].Offset {
+			t.Errorf("phrases out of order at offsets %d, %d, %d", result.Matches[0].Offset,
+				result.Matches[1].Offset, result.Matches[2].Offset)
+		}
+	}
+}
+
+func TestSearchQueryPinned(t *testing.T) {
+	library := newTestLibrary(t)
+	query := Query{Phrases: []Phrase{{Text: "dear diary", Line: 1, Column: 1}, {Text: "today"}}}
+	results, errs := library.SearchQueryStream(context.Background(), query, WithMaxResults(20))
+	collected := []*QueryResult{}
+	for result := range results {
+		collected = append(collected, result)
+		if result.Matches[0].Offset != 0 {
+			t.Errorf("expected the pinned phrase at offset 0, got %d", result.Matches[0].Offset)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(collected) != 20 {
+		t.Errorf("expected 20 results, got %d", len(collected))
+	}
+	if err := assertQueryResults(library, query, collected); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+}
+
+func TestSearchQueryInvalid(t *testing.T) {
+	library := newTestLibrary(t)
+	tests := map[string]Query{
+		"no phrases":          {},
+		"invalid characters":  {Phrases: []Phrase{{Text: "hello!"}}},
+		"line without column": {Phrases: []Phrase{{Text: "hello", Line: 2}}},
+		"overlapping pins": {Phrases: []Phrase{
+			{Text: "hello", Line: 1, Column: 1}, {Text: "world", Line: 1, Column: 3},
+		}},
+		"pins out of order": {Ordered: true, Phrases: []Phrase{
+			{Text: "hello", Line: 2, Column: 1}, {Text: "world", Line: 1, Column: 1},
+		}},
+		"no room between pins": {Ordered: true, Phrases: []Phrase{
+			{Text: "hello", Line: 1, Column: 1}, {Text: "big"}, {Text: "world", Line: 1, Column: 8},
+		}},
+	}
+	for name, query := range tests {
+		if _, err := library.SearchQueryPaginated(query, 0, 1); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+		if _, err := library.GetQueryCount(query); err == nil {
+			t.Errorf("%s: got nil, expected err from count", name)
+		}
+	}
+
+	query := Query{Phrases: []Phrase{{Text: "hello"}}}
+	if _, err := library.SearchQueryPaginated(query, 0, 1, WithLineStart()); err == nil {
+		t.Errorf("got nil, expected err for a position option")
+	}
+}
+
+func TestSearchQueryPacksPhrasesAroundPins(t *testing.T) {
+	geometry := DefaultGeometry
+	geometry.LinesPerPage, geometry.CharsPerLine = 1, 12
+	library := newTestLibrary(t, WithGeometry(geometry))
+	// the pin leaves gaps of 6 and 5, which only hold the phrases as "abc" "def" | "hello"
+	query := Query{Phrases: []Phrase{
+		{Text: "x", Line: 1, Column: 7}, {Text: "hello"}, {Text: "abc"}, {Text: "def"},
+	}}
+	results, err := library.SearchQueryPaginated(query, 0, 10)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(results) == 0 {
+		t.Fatal("expected results")
+	}
+	if err := assertQueryResults(library, query, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+}
+
+func TestGetQueryCountMatchesLayouts(t *testing.T) {
+	charset, err := NewCharset("ab", "ab")
+	if err != nil {
+		t.Fatalf("failed to build charset: %v", err)
+	}
+	geometry := DefaultGeometry
+	geometry.LinesPerPage, geometry.CharsPerLine = 1, 7
+	library := newTestLibrary(t, WithCharset(charset), WithGeometry(geometry))
+
+	queries := []Query{
+		{Phrases: []Phrase{{Text: "ab"}, {Text: "b"}}},
+		{Phrases: []Phrase{{Text: "ab"}, {Text: "b"}}, Ordered: true},
+		{Phrases: []Phrase{{Text: "ab", Line: 1, Column: 3}, {Text: "b"}, {Text: "a"}}},
+		{Phrases: []Phrase{{Text: "a"}, {Text: "ab", Line: 1, Column: 3}, {Text: "b"}}, Ordered: true},
+	}
+	for _, query := range queries {
+		// every assignment of offsets honouring the query, by brute force
+		var layouts func(i int, offsets []int) int64
+		layouts = func(i int, offsets []int) int64 {
+			if i == len(query.Phrases) {
+				return 1
+			}
+			count := int64(0)
+			phrase := query.Phrases[i]
+			for offset := 0; offset+len(phrase.Text) <= 7; offset++ {
+				if phrase.Line != 0 && offset != phrase.Column-1 {
+					continue
+				}
+				fits := true
+				for j, other := range offsets {
+					end, otherEnd := offset+len(phrase.Text), other+len(query.Phrases[j].Text)
+					if offset < otherEnd && other < end || query.Ordered && offset < other {
+						fits = false
+					}
+				}
+				if fits {
+					count += layouts(i+1, append(offsets, offset))
+				}
+			}
+			return count
+		}
+
+		length := 0
+		for _, phrase := range query.Phrases {
+			length += len(phrase.Text)
+		}
+		want := big.NewInt(layouts(0, nil) << (7 - length))
+		got, err := library.GetQueryCount(query)
+		if err != nil {
+			t.Fatalf("count failed: %v", err)
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("%+v: got %s, want %s", query, got, want)
+		}
+	}
+}
+
+func TestSearchQueryBlankWalksThroughLayouts(t *testing.T) {
+	charset, err := NewCharset("ab", "ab")
+	if err != nil {
+		t.Fatalf("failed to build charset: %v", err)
+	}
+	geometry := DefaultGeometry
+	geometry.LinesPerPage, geometry.CharsPerLine = 1, 7
+	library := newTestLibrary(t, WithCharset(charset), WithGeometry(geometry))
+
+	queries := []Query{
+		{Phrases: []Phrase{{Text: "bb"}, {Text: "b"}}},
+		{Phrases: []Phrase{{Text: "bb"}, {Text: "b"}}, Ordered: true},
+		{Phrases: []Phrase{{Text: "ba", Line: 1, Column: 3}, {Text: "b"}, {Text: "bb"}}},
+		{Phrases: []Phrase{{Text: "b"}, {Text: "ba", Line: 1, Column: 3}, {Text: "b"}}, Ordered: true},
+	}
+	for _, query := range queries {
+		count := library.ReachableQueryCount(query, WithFill(FillBlank))
+		results, err := library.SearchQueryPaginated(query, 0, count+1, WithFill(FillBlank))
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		if len(results) != count {
+			t.Errorf("%+v: got %d results, want %d", query, len(results), count)
+		}
+		if err := assertQueryResults(library, query, results); err != nil {
+			t.Errorf("%+v: results assertion failed: %v", query, err)
+		}
+		// every variant lays the phrases out differently
+		seen := map[string]bool{}
+		for _, result := range results {
+			offsets := []int{}
+			for _, match := range result.Matches {
+				offsets = append(offsets, match.Offset)
+			}
+			seen[fmt.Sprint(offsets)] = true
+		}
+		if len(seen) != len(results) {
+			t.Errorf("%+v: got %d distinct layouts from %d variants", query, len(seen), len(results))
+		}
+	}
+
+	// phrases the blank symbol cannot spell give a distinct page per variant
+	query := Query{Phrases: []Phrase{{Text: "alice"}, {Text: "bob"}, {Text: "carol"}}}
+	results, err := defaultLibrary.SearchQueryPaginated(query, 0, 200, WithFill(FillBlank))
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	seen := map[Location]bool{}
+	for _, result := range results {
+		seen[*result.Location] = true
+	}
+	if len(seen) != len(results) {
+		t.Errorf("got %d distinct pages from %d variants", len(seen), len(results))
+	}
+}
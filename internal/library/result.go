@@ -0,0 +1,84 @@
+package library
+
+// number of characters shown either side of a match in a snippet
+const snippetContext = 24
+
+// Match locates a run of text on a page
+type Match struct {
+	// index of the match's first character on the page, counting from 0
+	Offset int
+	// line and column of the match's first character, counting from 1
+	Line   int
+	Column int
+	// number of characters in the match
+	Length int
+}
+
+// Snippet is a match with a little of the page around it
+type Snippet struct {
+	Before string
+	Match  string
+	After  string
+}
+
+func (s Snippet) String() string {
+	return s.Before + s.Match + s.After
+}
+
+// SearchResult is a page containing the searched text and where on the page the text sits
+type SearchResult struct {
+	Location *Location
+	// index of the variant the page was generated from, see SearchPaginated
+	Variant int
+	Match
+	Snippet Snippet
+	// page offset of each character of text written in a layout other than LayoutRun,
+	// whose Match only covers the first character's line and column and whose Snippet
+	// holds the text alone
+	Cells []int
+}
+
+// searchResult generates the page holding variant of text, laid out as config describes.
+// The text must have passed validateSearch with config.
+func (l Library) searchResult(text string, variant int, config searchConfig) (*SearchResult, error) {
+	pageChars, placed, err := l.seedPageChars(text, variant, config)
+	if err != nil {
+		return nil, err
+	}
+	bigInt, err := l.pageCharsToBase29Number(pageChars)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		Location: l.locate(bigInt),
+		Variant:  variant,
+		Match:    l.newMatch(placed.cells[0], len(placed.text)),
+	}
+	if config.layout == LayoutRun {
+		result.Snippet = newSnippet(pageChars, result.Match)
+	} else {
+		result.Cells = placed.cells
+		result.Snippet = Snippet{Match: string(placed.text)}
+	}
+	return result, nil
+}
+
+func (l Library) newMatch(offset, length int) Match {
+	charsPerLine := l.geometry.CharsPerLine
+	return Match{
+		Offset: offset,
+		Line:   offset/charsPerLine + 1,
+		Column: offset%charsPerLine + 1,
+		Length: length,
+	}
+}
+
+func newSnippet(pageChars []rune, match Match) Snippet {
+	end := match.Offset + match.Length
+	return Snippet{
+		Before: string(pageChars[max(0, match.Offset-snippetContext):match.Offset]),
+		Match:  string(pageChars[match.Offset:end]),
+		After:  string(pageChars[end:min(len(pageChars), end+snippetContext)]),
+	}
+}
@@ -0,0 +1,49 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING search result positions and snippets
+*/
+
+func TestSearchResultPosition(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated(searchText, 0, 20)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+
+	for _, result := range results {
+		expectedLine := result.Offset/DefaultGeometry.CharsPerLine + 1
+		expectedColumn := result.Offset%DefaultGeometry.CharsPerLine + 1
+		if result.Line != expectedLine || result.Column != expectedColumn {
+			t.Errorf("offset %d: got line %d column %d, want line %d column %d",
+				result.Offset, result.Line, result.Column, expectedLine, expectedColumn)
+		}
+		if result.Length != len(searchText) {
+			t.Errorf("expected match length %d, got %d", len(searchText), result.Length)
+		}
+	}
+}
+
+func TestSnippetAtPageEdges(t *testing.T) {
+	page := []rune(strings.Repeat("x", 100))
+	copy(page, []rune("start"))
+	copy(page[95:], []rune("end.."))
+
+	start := newSnippet(page, Match{Offset: 0, Length: 5})
+	if start.Before != "" || start.Match != "start" || len(start.After) != snippetContext {
+		t.Errorf("unexpected snippet at page start: %+v", start)
+	}
+
+	end := newSnippet(page, Match{Offset: 95, Length: 5})
+	if end.After != "" || end.Match != "end.." || len(end.Before) != snippetContext {
+		t.Errorf("unexpected snippet at page end: %+v", end)
+	}
+	if end.String() != string(page[95-snippetContext:]) {
+		t.Errorf("snippet string does not match the page: %q", end)
+	}
+}
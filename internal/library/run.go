@@ -0,0 +1,104 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Run is a text too long for a single page written over several, a chunk of the text on
+// each page. Every chunk starts its page and all but the last fill it.
+//
+// A page's address is its content read as a number, so the pages of a run cannot be
+// consecutive pages of a book: neighbouring addresses hold pages differing only in their
+// last few characters, or pages scrambled apart. A run is the list of its pages instead,
+// in the order the text reads through them.
+type Run struct {
+	// page holding each chunk of the text, in order
+	Pages []*SearchResult
+	// index of the variant each page was generated from
+	Variant int
+}
+
+// Start is the location of the run's first page
+func (r Run) Start() *Location {
+	return r.Pages[0].Location
+}
+
+// SearchRun writes text of any length over as many pages as it needs, CharsPerPage
+// characters to a page. The pages are not consecutive pages of a book, each sits at its
+// own address anywhere in the library, see Run. Line breaks in text are read as spaces.
+// Fill and excluded text options apply to every page, options placing the text do not apply.
+func (l Library) SearchRun(text string, variant int, opts ...SearchOption) (*Run, error) {
+	config, err := newSearchConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.validateRunConfig(config); err != nil {
+		return nil, err
+	}
+	if variant < 0 {
+		return nil, fmt.Errorf("variant cannot be negative, got %d", variant)
+	}
+	if text == "" {
+		return nil, errors.New("text should not be empty")
+	}
+
+	// every chunk starts its page
+	config.position = position{kind: positionExact, line: 1, column: 1}
+	run := &Run{Variant: variant}
+	for _, chunk := range l.chunks(text) {
+		err := l.validateSearch(chunk, &config)
+		var result *SearchResult
+		if err == nil {
+			result, err = l.searchResult(chunk, variant, config)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("page %d of the run: %w", len(run.Pages)+1, err)
+		}
+		run.Pages = append(run.Pages, result)
+	}
+	return run, nil
+}
+
+// RunLength is the number of pages SearchRun writes text over
+func (l Library) RunLength(text string) int {
+	return len(l.chunks(text))
+}
+
+// chunks splits text into the pieces a run writes on each of its pages
+func (l Library) chunks(text string) []string {
+	textRunes := []rune(strings.NewReplacer("\r\n", " ", "\n", " ").Replace(text))
+	charsPerPage := l.geometry.CharsPerPage()
+	chunks := []string{}
+	for start := 0; start < len(textRunes); start += charsPerPage {
+		chunks = append(chunks, string(textRunes[start:min(start+charsPerPage, len(textRunes))]))
+	}
+	return chunks
+}
+
+// validateRunConfig checks the options apply to a run
+func (l Library) validateRunConfig(config searchConfig) error {
+	if config.position.kind != positionAnywhere {
+		return errors.New("position options do not apply to runs, each chunk starts its page")
+	}
+	if config.wildcards {
+		return errors.New("wildcards do not apply to runs")
+	}
+	if config.layout != LayoutRun {
+		return errors.New("layouts do not apply to runs")
+	}
+	if config.wordWrap {
+		return errors.New("word wrap does not apply to runs")
+	}
+	if config.shelving.pinned() {
+		return errors.New("shelving options do not apply to runs")
+	}
+	if config.shortest {
+		return errors.New("short addresses do not apply to runs")
+	}
+	if config.prefix != "" {
+		return errors.New("hexagon prefixes do not apply to runs")
+	}
+	return nil
+}
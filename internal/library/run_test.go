@@ -0,0 +1,85 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING runs of text over several pages
+*/
+
+func TestSearchRun(t *testing.T) {
+	library := newTestLibrary(t)
+	charsPerPage := DefaultGeometry.CharsPerPage()
+	text := strings.Repeat("it was the best of times, it was the worst of times\n", 200)
+
+	run, err := library.SearchRun(text, 3)
+	if err != nil {
+		t.Fatalf("search run failed: %v", err)
+	}
+	flat := strings.ReplaceAll(text, "\n", " ")
+	if want := (len(flat) + charsPerPage - 1) / charsPerPage; len(run.Pages) != want {
+		t.Fatalf("got %d pages, want %d", len(run.Pages), want)
+	}
+	if got := library.RunLength(text); got != len(run.Pages) {
+		t.Errorf("run length %d does not match %d pages", got, len(run.Pages))
+	}
+	if !run.Start().Equals(*run.Pages[0].Location) || run.Variant != 3 {
+		t.Errorf("run should start at its first page and keep its variant")
+	}
+
+	var read strings.Builder
+	for i, page := range run.Pages {
+		content, err := library.Browse(page.Location)
+		if err != nil {
+			t.Fatalf("failed to browse page %d: %v", i+1, err)
+		}
+		if page.Offset != 0 {
+			t.Errorf("page %d: chunk starts at offset %d, want 0", i+1, page.Offset)
+		}
+		read.WriteString(content[:page.Length])
+	}
+	if read.String() != flat {
+		t.Errorf("pages of the run do not read back as the text")
+	}
+}
+
+func TestSearchRunOfOnePage(t *testing.T) {
+	library := newTestLibrary(t)
+	run, err := library.SearchRun(searchText, 0, WithFill(FillBlank))
+	if err != nil {
+		t.Fatalf("search run failed: %v", err)
+	}
+	if len(run.Pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(run.Pages))
+	}
+	content, err := library.Browse(run.Start())
+	if err != nil {
+		t.Fatalf("failed to browse: %v", err)
+	}
+	if want := searchText + strings.Repeat(" ", DefaultGeometry.CharsPerPage()-len(searchText)); content != want {
+		t.Errorf("expected a blank page starting with the text")
+	}
+}
+
+func TestSearchRunWithInvalidOptions(t *testing.T) {
+	library := newTestLibrary(t)
+	tests := map[string][]SearchOption{
+		"position":  {WithLineStart()},
+		"wildcards": {WithWildcards()},
+		"layout":    {WithLayout(LayoutBlock)},
+		"word wrap": {WithWordWrap()},
+	}
+	for name, opts := range tests {
+		if _, err := library.SearchRun(searchText, 0, opts...); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+	if _, err := library.SearchRun("", 0); err == nil {
+		t.Errorf("empty text: got nil, expected err")
+	}
+	if _, err := library.SearchRun(strings.Repeat("a", 4000)+"!", 0); err == nil {
+		t.Errorf("invalid character on the second page: got nil, expected err")
+	}
+}
@@ -0,0 +1,113 @@
+package library
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+// number of Feistel rounds, anything from 4 up is indistinguishable from a random permutation
+const scrambleRounds = 6
+
+// scrambler is a keyed, invertible permutation of the numbers [0, limit).
+// It runs a balanced Feistel network over the smallest even number of bits covering the
+// limit and cycle walks, re-applying the network until the result falls back within range.
+type scrambler struct {
+	key   []byte
+	limit *big.Int
+	half  uint
+	mask  *big.Int
+}
+
+// WithScrambling places a keyed permutation between a page's contents and its address,
+// so neighbouring addresses hold unrelated pages. The same key always gives the same
+// library, keep it fixed to keep addresses valid across deployments.
+func WithScrambling(key string) Option {
+	return func(l *Library) error {
+		if key == "" {
+			return errors.New("scramble key should not be empty")
+		}
+		l.scrambleKey = []byte(key)
+		return nil
+	}
+}
+
+func newScrambler(key []byte, limit *big.Int) *scrambler {
+	bits := uint(new(big.Int).Sub(limit, big.NewInt(1)).BitLen())
+	if bits%2 == 1 {
+		bits++
+	}
+	half := max(bits/2, 1)
+	mask := new(big.Int).Lsh(big.NewInt(1), half)
+	mask.Sub(mask, big.NewInt(1))
+
+	return &scrambler{
+		key:   key,
+		limit: limit,
+		half:  half,
+		mask:  mask,
+	}
+}
+
+// forward maps a page's content number to its page number
+func (s *scrambler) forward(n *big.Int) *big.Int {
+	result := s.encrypt(n)
+	for result.Cmp(s.limit) >= 0 {
+		result = s.encrypt(result)
+	}
+	return result
+}
+
+// inverse maps a page number back to the content number stored there
+func (s *scrambler) inverse(n *big.Int) *big.Int {
+	result := s.decrypt(n)
+	for result.Cmp(s.limit) >= 0 {
+		result = s.decrypt(result)
+	}
+	return result
+}
+
+func (s *scrambler) encrypt(n *big.Int) *big.Int {
+	left := new(big.Int).Rsh(n, s.half)
+	right := new(big.Int).And(n, s.mask)
+	for round := range scrambleRounds {
+		left.Xor(left, s.roundFunction(round, right))
+		left, right = right, left
+	}
+	return left.Lsh(left, s.half).Or(left, right)
+}
+
+func (s *scrambler) decrypt(n *big.Int) *big.Int {
+	left := new(big.Int).Rsh(n, s.half)
+	right := new(big.Int).And(n, s.mask)
+	for round := scrambleRounds - 1; round >= 0; round-- {
+		left, right = right, left
+		left.Xor(left, s.roundFunction(round, right))
+	}
+	return left.Lsh(left, s.half).Or(left, right)
+}
+
+// roundFunction hashes the key, round and half block into a pseudo random half block,
+// stretching the digest in counter mode to cover as many bits as needed
+func (s *scrambler) roundFunction(round int, half *big.Int) *big.Int {
+	size := int(s.half+7) / 8
+
+	hasher := sha256.New()
+	hasher.Write(s.key)
+	hasher.Write([]byte{byte(round)})
+	hasher.Write(half.FillBytes(make([]byte, size)))
+	digest := hasher.Sum(nil)
+
+	stream := make([]byte, 0, size+sha256.Size)
+	block := make([]byte, sha256.Size+8)
+	copy(block, digest)
+	for counter := uint64(0); len(stream) < size; counter++ {
+		binary.BigEndian.PutUint64(block[sha256.Size:], counter)
+		sum := sha256.Sum256(block)
+		stream = append(stream, sum[:]...)
+	}
+
+	result := new(big.Int).SetBytes(stream[:size])
+	return result.And(result, s.mask)
+}
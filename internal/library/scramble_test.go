@@ -0,0 +1,118 @@
+package library
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const scrambleKey = "test key"
+
+/*
+	TESTING the scrambler permutation
+*/
+
+func TestScramblerIsAPermutation(t *testing.T) {
+	for _, limit := range []int64{2, 3, 1000, 4096} {
+		s := newScrambler([]byte(scrambleKey), big.NewInt(limit))
+		seen := make(map[int64]bool, limit)
+
+		for i := range limit {
+			scrambled := s.forward(big.NewInt(i))
+			if scrambled.Sign() < 0 || scrambled.Int64() >= limit {
+				t.Fatalf("limit %d: %d scrambled out of range to %d", limit, i, scrambled)
+			}
+			if seen[scrambled.Int64()] {
+				t.Fatalf("limit %d: %d scrambled onto a taken value %d", limit, i, scrambled)
+			}
+			seen[scrambled.Int64()] = true
+
+			if back := s.inverse(scrambled); back.Int64() != i {
+				t.Fatalf("limit %d: %d scrambled to %d and back to %d", limit, i, scrambled, back)
+			}
+		}
+	}
+}
+
+func TestScramblerIsKeyed(t *testing.T) {
+	limit := new(big.Int).Exp(big.NewInt(29), big.NewInt(3200), nil)
+	n := big.NewInt(123456789)
+
+	first := newScrambler([]byte(scrambleKey), limit).forward(n)
+	again := newScrambler([]byte(scrambleKey), limit).forward(n)
+	other := newScrambler([]byte("another key"), limit).forward(n)
+
+	if first.Cmp(again) != 0 {
+		t.Errorf("same key should scramble to the same number")
+	}
+	if first.Cmp(other) == 0 {
+		t.Errorf("different keys should scramble to different numbers")
+	}
+}
+
+func TestWithScramblingEmptyKey(t *testing.T) {
+	if _, err := NewLibrary(WithScrambling("")); err == nil {
+		t.Errorf("got nil, expected err for empty scramble key")
+	}
+}
+
+/*
+	TESTING search and browse in a scrambled library
+*/
+
+func TestScrambledLibrarySearchAndBrowse(t *testing.T) {
+	library := newTestLibrary(t, WithScrambling(scrambleKey))
+	if !library.Scrambled() {
+		t.Fatalf("library should report scrambling")
+	}
+
+	results, err := library.SearchPaginated(searchText, 0, 10)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+
+	plain := newTestLibrary(t)
+	plainResults, err := plain.SearchPaginated(searchText, 0, 1)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if plainResults[0].Location.Equals(*results[0].Location) {
+		t.Errorf("scrambled and plain libraries should place the page at different addresses")
+	}
+}
+
+func TestScrambledLibraryNeighboursAreUnrelated(t *testing.T) {
+	library := newTestLibrary(t, WithScrambling(scrambleKey))
+	location := &Location{Hexagon: "3a7f", Wall: 2, Shelf: 3, Book: 15, Page: 204}
+
+	page, err := library.Browse(location)
+	if err != nil {
+		t.Fatalf("failed to browse: %v", err)
+	}
+	next, err := library.Browse(location.Next())
+	if err != nil {
+		t.Fatalf("failed to browse: %v", err)
+	}
+
+	same := 0
+	for i := range page {
+		if page[i] == next[i] {
+			same++
+		}
+	}
+	// unrelated pages share roughly one character in 29
+	if limit := len(page) / 10; same > limit {
+		t.Errorf("neighbouring pages share %d characters, expected at most %d", same, limit)
+	}
+
+	// without scrambling the pages only differ at the end
+	plain := newTestLibrary(t)
+	plainPage, _ := plain.Browse(location)
+	plainNext, _ := plain.Browse(location.Next())
+	if !strings.HasPrefix(plainNext, plainPage[:len(plainPage)-10]) {
+		t.Errorf("expected neighbouring pages of a plain library to share a prefix")
+	}
+}
@@ -0,0 +1,189 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// SearchOption tunes how a search is carried out
+type SearchOption func(*searchConfig)
+
+type searchConfig struct {
+	workers    int
+	maxResults int
+	position   position
+	fill       Fill
+	wildcards  bool
+	excluded   []string
+	layout     Layout
+	wordWrap   bool
+	shelving   shelving
+	shortest   bool
+	prefix     string
+	// where word wrapped text can start, worked out once per search by validateSearch
+	wrapping *wrapping
+}
+
+// WithWorkers sets how many goroutines generate pages concurrently, runtime.NumCPU by default
+func WithWorkers(workers int) SearchOption {
+	return func(c *searchConfig) {
+		c.workers = workers
+	}
+}
+
+// WithMaxResults stops a search after n results, by default it runs through every variant
+func WithMaxResults(n int) SearchOption {
+	return func(c *searchConfig) {
+		c.maxResults = n
+	}
+}
+
+func newSearchConfig(opts []SearchOption) (searchConfig, error) {
+	config := searchConfig{workers: runtime.NumCPU(), fill: FillRandom, layout: LayoutRun}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.workers <= 0 {
+		return searchConfig{}, fmt.Errorf("workers must be positive, got %d", config.workers)
+	}
+	if config.maxResults < 0 {
+		return searchConfig{}, fmt.Errorf("max results cannot be negative, got %d", config.maxResults)
+	}
+	if err := config.fill.validate(); err != nil {
+		return searchConfig{}, err
+	}
+	if err := config.layout.validate(); err != nil {
+		return searchConfig{}, err
+	}
+	return config, nil
+}
+
+type variantJob[T any] struct {
+	variant int
+	result  chan<- variantResult[T]
+}
+
+type variantResult[T any] struct {
+	result T
+	err    error
+}
+
+// validateSearch checks text can be written on a page the way config lays it out, and
+// works out where word wrapped text can start so variants do not wrap it again
+func (l Library) validateSearch(text string, config *searchConfig) error {
+	validate := l.validateText
+	if config.wildcards {
+		validate = l.validatePattern
+	}
+	// line breaks in block text only move on to the next line
+	if err := validate(config.withoutLineBreaks(text)); err != nil {
+		return err
+	}
+	if config.fill == FillEnglish && len(l.words) == 0 {
+		return fmt.Errorf("charset %s cannot spell English filler", l.charset.Name())
+	}
+	if err := l.validateExcluded(text, *config); err != nil {
+		return err
+	}
+	if err := config.shelving.validate(l.geometry, l.Scrambled()); err != nil {
+		return err
+	}
+	if config.shortest {
+		if err := l.validateShortest(*config); err != nil {
+			return err
+		}
+	}
+	if config.prefix != "" {
+		if err := l.validateVanity(text, *config); err != nil {
+			return err
+		}
+	}
+	if err := l.validateLayout(text, config); err != nil {
+		return err
+	}
+	return l.validateShelving(text, *config)
+}
+
+// generateVariants computes the results of variants [first, last) on a pool of workers
+// and delivers them in variant order. Every variant gets a result slot queued in
+// order before it is handed to a worker; the queue's capacity bounds how far workers can
+// run ahead of the slowest variant still being waited on.
+// The error channel follows the same contract as SearchStream's.
+func generateVariants[T any](
+	ctx context.Context,
+	first, last, workers int,
+	generate func(variant int) (T, error),
+) (<-chan T, <-chan error) {
+	resultChan, errChan := make(chan T), make(chan error, 1)
+	searchCtx, cancel := context.WithCancel(ctx)
+	jobs := make(chan variantJob[T])
+	pending := make(chan chan variantResult[T], 2*workers)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for job := range jobs {
+				// result slots are buffered, so workers never block on a slow consumer
+				result, err := generate(job.variant)
+				if err != nil {
+					err = fmt.Errorf("error generating location for variant %d: %w", job.variant, err)
+				}
+				job.result <- variantResult[T]{result: result, err: err}
+			}
+		})
+	}
+
+	// queue a result slot per variant, then hand the variant to the workers
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for variant := first; variant < last; variant++ {
+			result := make(chan variantResult[T], 1)
+			select {
+			case pending <- result:
+			case <-searchCtx.Done():
+				return
+			}
+			select {
+			case jobs <- variantJob[T]{variant: variant, result: result}:
+			case <-searchCtx.Done():
+				return
+			}
+		}
+	}()
+
+	// deliver results in the order their slots were queued
+	go func() {
+		defer func() {
+			cancel()
+			wg.Wait()
+			close(resultChan)
+			close(errChan)
+		}()
+
+		for slot := range pending {
+			var result variantResult[T]
+			select {
+			case result = <-slot:
+			case <-searchCtx.Done():
+				errChan <- ctx.Err()
+				return
+			}
+			if result.err != nil {
+				errChan <- result.err
+				return
+			}
+			select {
+			case resultChan <- result.result:
+			case <-searchCtx.Done():
+				errChan <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return resultChan, errChan
+}
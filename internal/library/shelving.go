@@ -0,0 +1,389 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"slices"
+)
+
+const (
+	// most pages a scrambled library tries writing before giving up on a shelving
+	shelvingAttempts = 1 << 13
+	// how many times more runs a scrambled library draws from than it tries, so its
+	// attempts rarely draw the same run twice
+	shelvingSpread = 1 << 6
+	// most places a scrambled library's shelving can choose between, keeping the chance
+	// of running out of attempts, about e^-(shelvingAttempts/places), below one in a few thousand
+	maxScrambledShelving = 1 << 10
+)
+
+// shelving is the wall, shelf, book and page a search keeps its results on, nil for any
+type shelving struct {
+	wall  *int
+	shelf *int
+	book  *int
+	page  *int
+}
+
+// WithWall keeps results on a wall, counting from 0 like Location
+func WithWall(wall int) SearchOption {
+	return func(c *searchConfig) {
+		c.shelving.wall = &wall
+	}
+}
+
+// WithShelf keeps results on a shelf, counting from 0 like Location
+func WithShelf(shelf int) SearchOption {
+	return func(c *searchConfig) {
+		c.shelving.shelf = &shelf
+	}
+}
+
+// WithBook keeps results in a book, counting from 0 like Location
+func WithBook(book int) SearchOption {
+	return func(c *searchConfig) {
+		c.shelving.book = &book
+	}
+}
+
+// WithPage keeps results on a page of their book, counting from 1 like Location.
+//
+// Shelving options rewrite the last few free characters of each page, which read as the
+// lowest digits of the page's number, so the number falls on the chosen wall, shelf, book
+// or page. The text itself is never touched, so text ending the page can rule some places
+// out when the charset's size shares a factor with the address, like CharsetBorges' 25
+// with 410 pages a book. A scrambled library has no such shortcut and rewrites those
+// characters at random until the address fits, so it only shelves pages among at most
+// 1024 places, e.g. a page or a book but not both.
+func WithPage(page int) SearchOption {
+	return func(c *searchConfig) {
+		c.shelving.page = &page
+	}
+}
+
+// level is one part of the address below the hexagon, the radix it counts in and the
+// value a shelving pins it to, nil when free
+type level struct {
+	name   string
+	radix  int
+	pinned *int
+	// value of the first pinned value, 1 for pages
+	first int
+}
+
+// levels lists the parts of the address a shelving pins, lowest first
+func (s shelving) levels(geometry Geometry) []level {
+	return []level{
+		{"page", geometry.PagesPerBook, s.page, 1},
+		{"book", geometry.BooksPerShelf, s.book, 0},
+		{"shelf", geometry.ShelvesPerWall, s.shelf, 0},
+		{"wall", geometry.WallsPerHexagon, s.wall, 0},
+	}
+}
+
+func (s shelving) pinned() bool {
+	return s.wall != nil || s.shelf != nil || s.book != nil || s.page != nil
+}
+
+// places is the number of places the pinned parts of the address can take between them
+func (s shelving) places(geometry Geometry) int {
+	places := 1
+	for _, level := range s.levels(geometry) {
+		if level.pinned != nil {
+			places *= level.radix
+		}
+	}
+	return places
+}
+
+// validate checks the pinned parts are in range, and a scrambled library can shelve them
+func (s shelving) validate(geometry Geometry, scrambled bool) error {
+	for _, level := range s.levels(geometry) {
+		if level.pinned == nil {
+			continue
+		}
+		if value := *level.pinned; value < level.first || value >= level.radix+level.first {
+			return fmt.Errorf("%s must be between %d and %d, got %d",
+				level.name, level.first, level.radix+level.first-1, value)
+		}
+	}
+	if places := s.places(geometry); scrambled && places > maxScrambledShelving {
+		return fmt.Errorf("a scrambled library cannot shelve a page among %d places, at most %d",
+			places, maxScrambledShelving)
+	}
+	return nil
+}
+
+// validateShelving checks text pinned to a line and column can be shelved. Every variant
+// leaves the same characters free, and the text near the end of the page can fix the page
+// number modulo factors shared with the charset's size, see reach, so a shelving it rules
+// out is rejected before any variant is written.
+func (l Library) validateShelving(text string, config searchConfig) error {
+	if !config.shelving.pinned() || l.scrambler != nil || config.position.kind != positionExact ||
+		config.wordWrap || config.wildcards {
+		return nil
+	}
+	config.fill, config.excluded = FillBlank, nil
+	_, _, err := l.seedPageChars(text, 0, config)
+	return err
+}
+
+// target is the modulus spanning the parts of the address up to the highest pinned one,
+// and the residue page numbers take modulo it to land on the pinned parts, the free parts
+// below keeping their value in n
+func (s shelving) target(n *big.Int, geometry Geometry) (*big.Int, *big.Int) {
+	modulus, residue := big.NewInt(1), big.NewInt(0)
+	rest := new(big.Int).Set(n)
+	digit := new(big.Int)
+	highest := new(big.Int)
+	for _, level := range s.levels(geometry) {
+		radix := big.NewInt(int64(level.radix))
+		rest.DivMod(rest, radix, digit)
+		if level.pinned != nil {
+			digit.SetInt64(int64(*level.pinned - level.first))
+		}
+		residue.Add(residue, new(big.Int).Mul(digit, modulus))
+		modulus.Mul(modulus, radix)
+		if level.pinned != nil {
+			highest.Set(modulus)
+		}
+	}
+	return highest, residue.Mod(residue, highest)
+}
+
+// shelve rewrites free characters of pageChars so the page lands on the shelving's wall,
+// shelf, book and page, returning which characters it rewrote. Characters marked occupied
+// are left alone. Scrambled libraries draw the rewritten characters from rng.
+func (l Library) shelve(pageChars []rune, occupied []bool, s shelving, rng *rand.Rand) ([]bool, error) {
+	if !s.pinned() {
+		return nil, nil
+	}
+	n, err := l.pageCharsToBase29Number(pageChars)
+	if err != nil {
+		return nil, err
+	}
+	if l.scrambler != nil {
+		return l.shelveScrambled(pageChars, occupied, s, n, rng)
+	}
+
+	modulus, residue := s.target(n, l.geometry)
+	rewritten := make([]bool, len(pageChars))
+	if err := l.reach(pageChars, occupied, rewritten, n, modulus, residue, 0); err != nil {
+		return nil, err
+	}
+	return rewritten, nil
+}
+
+// reach rewrites free characters of pageChars from the from'th on so the page number n
+// becomes residue modulo modulus, updating n and marking the characters rewritten.
+//
+// A run of characters reaches every residue modulo modulus when its place value can be
+// divided out, which always holds for the run ending the page. Higher up, the place value
+// shares factors with the modulus when the charset's size does, e.g. 5 in CharsetBorges'
+// 25 and a page count of 410, and the run only reaches the residues modulo what is left.
+// The free characters below it then reach the residue modulo the shared factors first.
+// The characters below the lowest free one fix the page number modulo those factors, so
+// text ending the page can rule a shelving out.
+func (l Library) reach(pageChars []rune, occupied, rewritten []bool, n, modulus, residue *big.Int, from int) error {
+	one := big.NewInt(1)
+	if new(big.Int).Mod(n, modulus).Cmp(residue) == 0 {
+		return nil
+	}
+	lowest := len(pageChars) - 1
+	for lowest >= from && occupied[lowest] {
+		lowest--
+	}
+	fixed := new(big.Int).Set(modulus)
+	if lowest >= from {
+		placeValue := new(big.Int).Exp(l.base, big.NewInt(int64(len(pageChars)-1-lowest)), modulus)
+		fixed.GCD(nil, nil, placeValue, modulus)
+	}
+	if new(big.Int).Mod(n, fixed).Cmp(new(big.Int).Mod(residue, fixed)) != 0 {
+		return fmt.Errorf("text near the end of the page fixes its number modulo %d, "+
+			"which keeps it off the chosen wall, shelf, book or page", fixed)
+	}
+
+	// the lowest run of free characters, its place value running up from the end of the page
+	first := 0
+	placeValue := big.NewInt(1)
+	for place := 0; place < len(pageChars)-from; place++ {
+		if place > 0 {
+			placeValue.Mod(placeValue.Mul(placeValue, l.base), modulus)
+		}
+		shared := new(big.Int).GCD(nil, nil, placeValue, modulus)
+		reachable := new(big.Int).Div(modulus, shared)
+		if reachable.Cmp(one) == 0 {
+			break
+		}
+		width := l.digitsCovering(reachable)
+		if first == 0 {
+			first = width
+		}
+		start := len(pageChars) - place - width
+		if start < from {
+			break
+		}
+		if !l.free(occupied, start, width) {
+			continue
+		}
+		below := new(big.Int).Mod(residue, shared)
+		if err := l.reach(pageChars, occupied, rewritten, n, shared, below, start+width); err != nil {
+			continue
+		}
+
+		// the rest of the page number, without the run's contribution, which now matches the
+		// residue modulo the shared factors
+		old := l.readDigits(pageChars[start : start+width])
+		rest := new(big.Int).Mul(old, placeValue)
+		rest.Sub(n, rest)
+
+		run := rest.Sub(residue, rest)
+		run.Mod(run, modulus).Div(run, shared)
+		run.Mul(run, new(big.Int).ModInverse(new(big.Int).Div(placeValue, shared), reachable))
+		run.Mod(run, reachable)
+		l.writeDigits(pageChars[start:start+width], run)
+		for cell := start; cell < start+width; cell++ {
+			rewritten[cell] = true
+		}
+
+		change := new(big.Int).Sub(run, old)
+		n.Add(n, change.Mul(change, new(big.Int).Exp(l.base, big.NewInt(int64(place)), nil)))
+		return nil
+	}
+	return fmt.Errorf("text leaves no %d free characters in a row to shelve the page with", first)
+}
+
+// shelveScrambled rewrites the lowest run of free characters at random until the scrambled
+// page number lands on the shelving. The run is wide enough to hold far more numbers than
+// the attempts draw, so each attempt is a fresh chance of landing on the shelving.
+func (l Library) shelveScrambled(pageChars []rune, occupied []bool, s shelving, n *big.Int, rng *rand.Rand) ([]bool, error) {
+	draws := big.NewInt(int64(s.places(l.geometry)) * shelvingAttempts * shelvingSpread)
+	width := l.digitsCovering(draws)
+	start := len(pageChars) - width
+	for start >= 0 && !l.free(occupied, start, width) {
+		start--
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("text leaves no %d free characters in a row to shelve the page with", width)
+	}
+
+	placeValue := new(big.Int).Exp(l.base, big.NewInt(int64(len(pageChars)-start-width)), nil)
+	rest := new(big.Int).Mul(l.readDigits(pageChars[start:start+width]), placeValue)
+	rest.Sub(n, rest)
+	runs := new(big.Int).Exp(l.base, big.NewInt(int64(width)), nil)
+	for range shelvingAttempts {
+		run := new(big.Int).Rand(rng, runs)
+		candidate := new(big.Int).Mul(run, placeValue)
+		candidate.Add(candidate, rest)
+
+		index := l.scrambler.forward(candidate)
+		if modulus, residue := s.target(index, l.geometry); new(big.Int).Mod(index, modulus).Cmp(residue) == 0 {
+			l.writeDigits(pageChars[start:start+width], run)
+			rewritten := make([]bool, len(pageChars))
+			for cell := start; cell < start+width; cell++ {
+				rewritten[cell] = true
+			}
+			return rewritten, nil
+		}
+	}
+	return nil, errors.New("could not shelve the page, try another variant")
+}
+
+// shelveAvoiding shelves the page as shelve does, keeping config's excluded text off it.
+// The rewritten characters may spell excluded text, in which case a free character that
+// was not rewritten is redrawn, which moves the digits shelving needs, and the page
+// shelved again.
+func (l Library) shelveAvoiding(pageChars []rune, occupied []bool, config searchConfig, rng *rand.Rand) error {
+	words := make([][]rune, len(config.excluded))
+	for i, text := range config.excluded {
+		words[i] = []rune(l.charset.Fold(text))
+	}
+	for range exclusionPasses {
+		rewritten, err := l.shelve(pageChars, occupied, config.shelving, rng)
+		if err != nil || !config.shelving.pinned() {
+			return err
+		}
+		at, length := findExcluded(pageChars, words)
+		if at < 0 {
+			return nil
+		}
+
+		// a free character of the excluded text that was not rewritten, or any such
+		// character when the rewritten ones spell all of it
+		redrawable := func(cell int) bool {
+			return !occupied[cell] && !rewritten[cell]
+		}
+		cells := []int{}
+		for cell := at; cell < at+length; cell++ {
+			if redrawable(cell) {
+				cells = append(cells, cell)
+			}
+		}
+		if len(cells) == 0 {
+			for cell := range pageChars {
+				if redrawable(cell) {
+					cells = append(cells, cell)
+				}
+			}
+		}
+		if len(cells) == 0 {
+			return errors.New("text leaves no free characters to keep excluded text off the shelved page")
+		}
+		cell := cells[rng.Intn(len(cells))]
+		pageChars[cell] = l.otherSymbol(pageChars[cell], rng)
+	}
+	return errors.New("could not keep excluded text off the shelved page")
+}
+
+// findExcluded is where the first of words appears on the page and its length, -1 when none does
+func findExcluded(pageChars []rune, words [][]rune) (int, int) {
+	for _, word := range words {
+		for i := 0; i+len(word) <= len(pageChars); i++ {
+			if slices.Equal(pageChars[i:i+len(word)], word) {
+				return i, len(word)
+			}
+		}
+	}
+	return -1, 0
+}
+
+// digitsCovering is the fewest characters whose numbers reach every residue modulo modulus
+func (l Library) digitsCovering(modulus *big.Int) int {
+	width := 1
+	for covered := new(big.Int).Set(l.base); covered.Cmp(modulus) < 0; covered.Mul(covered, l.base) {
+		width++
+	}
+	return width
+}
+
+// free reports whether none of the width characters from start is occupied
+func (l Library) free(occupied []bool, start, width int) bool {
+	for _, taken := range occupied[start : start+width] {
+		if taken {
+			return false
+		}
+	}
+	return true
+}
+
+// readDigits reads characters as a number, the first one most significant
+func (l Library) readDigits(chars []rune) *big.Int {
+	n := big.NewInt(0)
+	for _, char := range chars {
+		n.Mul(n, l.base)
+		n.Add(n, big.NewInt(int64(l.charset.index[char])))
+	}
+	return n
+}
+
+// writeDigits writes n over chars, the last character least significant
+func (l Library) writeDigits(chars []rune, n *big.Int) {
+	n = new(big.Int).Set(n)
+	digit := new(big.Int)
+	for i := len(chars) - 1; i >= 0; i-- {
+		n.DivMod(n, l.base, digit)
+		chars[i] = l.charset.symbols[digit.Int64()]
+	}
+}
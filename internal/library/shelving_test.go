@@ -0,0 +1,193 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING searches shelved on a wall, shelf, book or page
+*/
+
+func TestSearchWithShelving(t *testing.T) {
+	tests := map[string]struct {
+		opts  []SearchOption
+		check func(*Location) bool
+	}{
+		"page":      {[]SearchOption{WithPage(1)}, func(l *Location) bool { return l.Page == 1 }},
+		"last page": {[]SearchOption{WithPage(410)}, func(l *Location) bool { return l.Page == 410 }},
+		"book":      {[]SearchOption{WithBook(7)}, func(l *Location) bool { return l.Book == 7 }},
+		"shelf":     {[]SearchOption{WithShelf(4)}, func(l *Location) bool { return l.Shelf == 4 }},
+		"wall":      {[]SearchOption{WithWall(2)}, func(l *Location) bool { return l.Wall == 2 }},
+		"everything": {
+			[]SearchOption{WithWall(3), WithShelf(0), WithBook(31), WithPage(205)},
+			func(l *Location) bool { return l.Wall == 3 && l.Shelf == 0 && l.Book == 31 && l.Page == 205 },
+		},
+	}
+	library := newTestLibrary(t)
+	for name, test := range tests {
+		results, err := library.SearchPaginated(searchText, 0, 10, test.opts...)
+		if err != nil {
+			t.Fatalf("%s: search failed: %v", name, err)
+		}
+		if err := assertSearchResults(library, results); err != nil {
+			t.Errorf("%s: results assertion failed: %v", name, err)
+		}
+		for _, result := range results {
+			if !test.check(result.Location) {
+				t.Errorf("%s: result landed on %d.%d.%d.%d", name,
+					result.Location.Wall, result.Location.Shelf, result.Location.Book, result.Location.Page)
+			}
+		}
+	}
+}
+
+func TestSearchWithShelvingKeepsTextAtPageEnd(t *testing.T) {
+	library := newTestLibrary(t)
+	charsPerPage := DefaultGeometry.CharsPerPage()
+	// the text ends the page, so the characters before it are rewritten
+	results, err := library.SearchPaginated(searchText, 0, 5,
+		WithPosition(40, 70), WithFill(FillBlank), WithPage(1), WithBook(3))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		page, err := library.Browse(result.Location)
+		if err != nil {
+			t.Fatalf("failed to browse: %v", err)
+		}
+		if !strings.HasSuffix(page, searchText) {
+			t.Errorf("text should still end the page, got %q", page[charsPerPage-20:])
+		}
+		if result.Location.Page != 1 || result.Location.Book != 3 {
+			t.Errorf("got book %d, page %d, want book 3, page 1", result.Location.Book, result.Location.Page)
+		}
+	}
+}
+
+func TestSearchWithShelvingInScrambledLibrary(t *testing.T) {
+	library := newTestLibrary(t, WithScrambling("shelving"))
+	results, err := library.SearchPaginated(searchText, 0, 3, WithPage(1))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Location.Page != 1 {
+			t.Errorf("got page %d, want 1", result.Location.Page)
+		}
+	}
+
+	if _, err := library.SearchPaginated(searchText, 0, 1, WithPage(1), WithBook(0)); err == nil {
+		t.Errorf("expected err shelving a scrambled page among 13120 places, got nil")
+	}
+}
+
+func TestSearchWithShelvingInScrambledLibraryShelvesEveryVariant(t *testing.T) {
+	library := newTestLibrary(t, WithScrambling("shelving"))
+	// 160 places, a few times fewer than the numbers two characters write, so drawing only
+	// two characters would leave some variants unshelved
+	results, err := library.SearchPaginated(searchText, 0, 256, WithShelf(4), WithBook(30))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Location.Shelf != 4 || result.Location.Book != 30 {
+			t.Errorf("variant %d: got shelf %d, book %d, want shelf 4, book 30",
+				result.Variant, result.Location.Shelf, result.Location.Book)
+		}
+	}
+}
+
+func TestSearchWithShelvingAvoidsExcludedText(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated(searchText, 0, 100, WithPage(1), WithBook(3), WithExcluded("z"))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+	for _, result := range results {
+		page, err := library.Browse(result.Location)
+		if err != nil {
+			t.Fatalf("failed to browse: %v", err)
+		}
+		if strings.Contains(page, "z") {
+			t.Errorf("variant %d: page spells excluded text", result.Variant)
+		}
+		if result.Location.Page != 1 || result.Location.Book != 3 {
+			t.Errorf("got book %d, page %d, want book 3, page 1", result.Location.Book, result.Location.Page)
+		}
+	}
+}
+
+func TestSearchWithInvalidShelving(t *testing.T) {
+	library := newTestLibrary(t)
+	tests := map[string][]SearchOption{
+		"page 0":    {WithPage(0)},
+		"page 411":  {WithPage(411)},
+		"book 32":   {WithBook(32)},
+		"shelf -1":  {WithShelf(-1)},
+		"wall 4":    {WithWall(4)},
+		"full page": {WithPage(1), WithPosition(1, 1)},
+	}
+	for name, opts := range tests {
+		text := searchText
+		if name == "full page" {
+			text = strings.Repeat("a", DefaultGeometry.CharsPerPage()-1)
+		}
+		if _, err := library.SearchPaginated(text, 0, 1, opts...); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+	if got := library.ReachableCount(searchText, WithPage(0)); got != 0 {
+		t.Errorf("got %d reachable pages for page 0, want 0", got)
+	}
+}
+
+func TestSearchWithShelvingOnCharsetSharingFactors(t *testing.T) {
+	// 25 symbols and 410 pages a book share a factor of 5, so the last character of the
+	// page fixes the page modulo 5: "l" reads as 11, which only lands on pages 2, 7, 12...
+	library := newTestLibrary(t, WithCharset(CharsetBorges))
+	text := "la biblioteca es total"
+	endColumn := DefaultGeometry.CharsPerLine - len(text) + 1
+	tests := map[string]struct {
+		column int
+		page   int
+	}{
+		"text ending the page":         {endColumn, 7},
+		"a character below the text":   {endColumn - 1, 5},
+		"text far from the page's end": {1, 5},
+	}
+	for name, test := range tests {
+		results, err := library.SearchPaginated(text, 0, 20,
+			WithPosition(DefaultGeometry.LinesPerPage, test.column), WithPage(test.page), WithBook(9))
+		if err != nil {
+			t.Fatalf("%s: search failed: %v", name, err)
+		}
+		if len(results) != 20 {
+			t.Errorf("%s: got %d results, want 20", name, len(results))
+		}
+		for _, result := range results {
+			page, err := library.Browse(result.Location)
+			if err != nil {
+				t.Fatalf("%s: failed to browse: %v", name, err)
+			}
+			if !strings.Contains(page, text) {
+				t.Errorf("%s: variant %d does not hold the text", name, result.Variant)
+			}
+			if result.Location.Page != test.page || result.Location.Book != 9 {
+				t.Errorf("%s: got book %d, page %d, want book 9, page %d",
+					name, result.Location.Book, result.Location.Page, test.page)
+			}
+		}
+	}
+
+	_, err := library.SearchPaginated(text, 0, 1, WithPosition(DefaultGeometry.LinesPerPage, endColumn), WithPage(5))
+	if err == nil || !strings.Contains(err.Error(), "modulo 5") {
+		t.Errorf("got %v, expected err ruling out page 5", err)
+	}
+}
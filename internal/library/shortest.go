@@ -0,0 +1,93 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// WithShortestAddress finds the pages holding text with the shortest addresses. A page's
+// number is its content read as a base-N number, so the text ends the page and every
+// character before it is the charset's first symbol, which reads as zero. Variants count
+// up in the free characters just before the text, each variant's address no longer than
+// the next one's. The fill is ignored.
+//
+// Shelving options apply, rewriting the last few characters of the page, so the text ends
+// just above them. Options placing the text, wildcards and excluded text do not, and
+// neither does a scrambled library, whose addresses are unrelated to their pages' content.
+func WithShortestAddress() SearchOption {
+	return func(c *searchConfig) {
+		c.shortest = true
+	}
+}
+
+// validateShortest checks config can be searched for short addresses
+func (l Library) validateShortest(config searchConfig) error {
+	switch {
+	case l.scrambler != nil:
+		return errors.New("a scrambled library has no short addresses to search for")
+	case config.position.kind != positionAnywhere:
+		return errors.New("position options do not apply to short addresses, the text ends the page")
+	case config.layout != LayoutRun:
+		return fmt.Errorf("the %s layout does not apply to short addresses", config.layout)
+	case config.wordWrap:
+		return errors.New("word wrap does not apply to short addresses")
+	case config.wildcards:
+		return errors.New("wildcards do not apply to short addresses")
+	case len(config.excluded) > 0:
+		return errors.New("excluded text does not apply to short addresses")
+	}
+	return nil
+}
+
+// shelvingWidth is the number of characters shelving rewrites, left free below the text of
+// a short address. The run ending the page always reaches every residue, whatever the
+// charset, see reach.
+func (l Library) shelvingWidth(config searchConfig) int {
+	if !config.shelving.pinned() {
+		return 0
+	}
+	modulus, _ := config.shelving.target(big.NewInt(0), l.geometry)
+	return l.digitsCovering(modulus)
+}
+
+// writeShortVariant writes variant in the free characters just before the text, marking
+// them occupied
+func (l Library) writeShortVariant(pageChars []rune, occupied []bool, variant int) error {
+	end := slices.Index(occupied, true)
+	width := 0
+	for n := variant; n > 0; n /= l.charset.Size() {
+		width++
+	}
+	if width > end {
+		return fmt.Errorf("variant %d does not fit in the %d characters before the text", variant, max(end, 0))
+	}
+	l.writeDigits(pageChars[end-width:end], big.NewInt(int64(variant)))
+	for cell := end - width; cell < end; cell++ {
+		occupied[cell] = true
+	}
+	return nil
+}
+
+// shortestCount is the number of variants of text with a short address
+func (l Library) shortestCount(length int, config searchConfig) *big.Int {
+	free := l.geometry.CharsPerPage() - length - l.shelvingWidth(config)
+	if free < 0 {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Exp(l.base, big.NewInt(int64(free)), nil)
+}
+
+// HexagonLength is the number of characters in the hexagon of the result's address
+func (r SearchResult) HexagonLength() int {
+	return len(r.Location.Hexagon)
+}
+
+// SortByHexagonLength orders results by the length of their hexagon, shortest first,
+// keeping results of the same length in variant order
+func SortByHexagonLength(results []*SearchResult) {
+	slices.SortStableFunc(results, func(a, b *SearchResult) int {
+		return a.HexagonLength() - b.HexagonLength()
+	})
+}
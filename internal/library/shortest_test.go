@@ -0,0 +1,163 @@
+package library
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING searches for the shortest addresses
+*/
+
+func TestSearchWithShortestAddress(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated(searchText, 0, 50, WithShortestAddress())
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+
+	// the first variant is the text alone at the end of a blank page
+	page, err := library.Browse(results[0].Location)
+	if err != nil {
+		t.Fatalf("failed to browse: %v", err)
+	}
+	charsPerPage := DefaultGeometry.CharsPerPage()
+	if want := strings.Repeat(" ", charsPerPage-len(searchText)) + searchText; page != want {
+		t.Errorf("first variant should be the text at the end of a blank page")
+	}
+	// 11 base-29 digits over 4·5·32·410 places below the hexagon, in base 36
+	if got := results[0].HexagonLength(); got > 8 {
+		t.Errorf("got a hexagon of %d characters, want at most 8", got)
+	}
+
+	seen := map[string]bool{}
+	for i, result := range results {
+		if result.Offset != charsPerPage-len(searchText) {
+			t.Errorf("variant %d: text at offset %d, want it to end the page", i, result.Offset)
+		}
+		if i > 0 && result.HexagonLength() < results[i-1].HexagonLength() {
+			t.Errorf("variant %d has a shorter hexagon than variant %d", i, i-1)
+		}
+		seen[result.Location.String()] = true
+	}
+	if len(seen) != len(results) {
+		t.Errorf("got %d distinct pages from %d variants", len(seen), len(results))
+	}
+}
+
+func TestSearchWithShortestAddressAndShelving(t *testing.T) {
+	library := newTestLibrary(t)
+	results, err := library.SearchPaginated(searchText, 0, 30, WithShortestAddress(), WithPage(1))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, result := range results {
+		if result.Location.Page != 1 {
+			t.Errorf("got page %d, want 1", result.Location.Page)
+		}
+		if result.HexagonLength() > 10 {
+			t.Errorf("got a hexagon of %d characters, want at most 10", result.HexagonLength())
+		}
+		seen[result.Location.String()] = true
+	}
+	if len(seen) != len(results) {
+		t.Errorf("got %d distinct pages from %d variants", len(seen), len(results))
+	}
+}
+
+func TestSearchWithShortestAddressAndShelvingOnOtherCharsets(t *testing.T) {
+	// both sizes share a factor of 5 with 410 pages a book, so shelving rewrites the end of
+	// the page rather than characters above the text
+	tests := map[string]struct {
+		charset *Charset
+		text    string
+	}{
+		"borges":       {CharsetBorges, "la biblioteca es total"},
+		"alphanumeric": {CharsetAlphanumeric, "Hello World"},
+	}
+	for name, test := range tests {
+		library := newTestLibrary(t, WithCharset(test.charset))
+		results, err := library.SearchPaginated(test.text, 0, 30, WithShortestAddress(), WithPage(7))
+		if err != nil {
+			t.Fatalf("%s: search failed: %v", name, err)
+		}
+		if len(results) != 30 {
+			t.Errorf("%s: got %d results, want 30", name, len(results))
+		}
+		seen := map[string]bool{}
+		for _, result := range results {
+			page, err := library.Browse(result.Location)
+			if err != nil {
+				t.Fatalf("%s: failed to browse: %v", name, err)
+			}
+			if !strings.Contains(page, test.text) {
+				t.Errorf("%s: variant %d does not hold the text", name, result.Variant)
+			}
+			if result.Location.Page != 7 {
+				t.Errorf("%s: got page %d, want 7", name, result.Location.Page)
+			}
+			seen[result.Location.String()] = true
+		}
+		if len(seen) != len(results) {
+			t.Errorf("%s: got %d distinct pages from %d variants", name, len(seen), len(results))
+		}
+	}
+}
+
+func TestSearchWithShortestAddressCountsVariants(t *testing.T) {
+	library := newTestLibrary(t)
+	text := strings.Repeat("a", DefaultGeometry.CharsPerPage()-2)
+	if got := library.ReachableCount(text, WithShortestAddress()); got != 29*29 {
+		t.Errorf("got %d reachable pages, want %d", got, 29*29)
+	}
+	results, err := library.SearchPaginated(text, 29*29-3, 10, WithShortestAddress())
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("got %d results, want 3", len(results))
+	}
+}
+
+func TestSearchWithInvalidShortestAddress(t *testing.T) {
+	tests := map[string][]SearchOption{
+		"position":  {WithShortestAddress(), WithLineStart()},
+		"layout":    {WithShortestAddress(), WithLayout(LayoutVertical)},
+		"word wrap": {WithShortestAddress(), WithWordWrap()},
+		"wildcards": {WithShortestAddress(), WithWildcards()},
+		"excluded":  {WithShortestAddress(), WithExcluded("x")},
+	}
+	library := newTestLibrary(t)
+	for name, opts := range tests {
+		if _, err := library.SearchPaginated(searchText, 0, 1, opts...); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+	scrambled := newTestLibrary(t, WithScrambling("short"))
+	if _, err := scrambled.SearchPaginated(searchText, 0, 1, WithShortestAddress()); err == nil {
+		t.Errorf("scrambled: got nil, expected err")
+	}
+}
+
+func TestSortByHexagonLength(t *testing.T) {
+	results := []*SearchResult{}
+	for variant, hexagon := range []string{"abc", "a", "abcd", "b", "ab"} {
+		results = append(results, &SearchResult{Location: &Location{Hexagon: hexagon}, Variant: variant})
+	}
+	SortByHexagonLength(results)
+	variants := []int{}
+	for _, result := range results {
+		variants = append(variants, result.Variant)
+	}
+	if want := []int{1, 3, 4, 0, 2}; !slices.Equal(variants, want) {
+		t.Errorf("got variants %v, want %v", variants, want)
+	}
+}
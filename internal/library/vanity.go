@@ -0,0 +1,133 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// WithHexagonPrefix finds pages whose hexagon starts with prefix, a base-36 string other
+// than 0, which no hexagon but 0 itself starts with. The hexagon is read from the most
+// significant digits of a page's number, its first characters, so the first few
+// characters of each page are fixed to spell the prefix and the text is placed below
+// them. The longer the prefix, the more characters it fixes.
+//
+// The prefix applies to LayoutRun without wildcards or word wrap, and not to short
+// addresses or a scrambled library, whose addresses are unrelated to their pages' content.
+func WithHexagonPrefix(prefix string) SearchOption {
+	return func(c *searchConfig) {
+		c.prefix = prefix
+	}
+}
+
+// validateVanity checks text can be placed on pages whose hexagon starts with config's prefix
+func (l Library) validateVanity(text string, config searchConfig) error {
+	switch {
+	case l.scrambler != nil:
+		return errors.New("a scrambled library cannot choose its hexagons")
+	case config.layout != LayoutRun:
+		return fmt.Errorf("the %s layout does not apply to hexagon prefixes", config.layout)
+	case config.wordWrap:
+		return errors.New("word wrap does not apply to hexagon prefixes")
+	case config.wildcards:
+		return errors.New("wildcards do not apply to hexagon prefixes")
+	case config.shortest:
+		return errors.New("short addresses cannot take a hexagon prefix")
+	}
+	top, err := l.hexagonPrefixChars(config.prefix)
+	if err != nil {
+		return err
+	}
+
+	length := config.textLength(text)
+	if len(l.vanityCandidates(length, len(top), config.position)) > 0 {
+		return nil
+	}
+	if config.position.kind == positionExact {
+		return fmt.Errorf("text at line %d, column %d overlaps the first %d characters of the page, which hexagon prefix %q fixes",
+			config.position.line, config.position.column, len(top), config.prefix)
+	}
+	return fmt.Errorf("hexagon prefix %q fixes the first %d characters of the page, leaving no room for the text",
+		config.prefix, len(top))
+}
+
+// hexagonPrefixChars is the shortest run of characters starting a page that puts the page
+// in a hexagon starting with prefix. Hexagons are made as long as the library allows, so
+// the prefix leaves the widest range of page numbers to choose from and fixes the fewest
+// characters.
+func (l Library) hexagonPrefixChars(prefix string) ([]rune, error) {
+	value, ok := new(big.Int).SetString(prefix, 36)
+	if !ok || value.Sign() < 0 || value.Text(36) != prefix {
+		return nil, fmt.Errorf("hexagon prefix %q must be a base-36 number without leading zeros", prefix)
+	}
+	// only hexagon 0 itself starts with 0, every longer hexagon starts with another digit
+	if value.Sign() == 0 {
+		return nil, errors.New("hexagon prefix cannot be 0, only hexagon 0 starts with it")
+	}
+
+	// the range of hexagons starting with the prefix, at the longest length reaching into the library
+	maxHexagon := l.MaxHexagon()
+	low, high := new(big.Int), new(big.Int)
+	found := false
+	for length := len(maxHexagon.Text(36)); length >= len(prefix) && !found; length-- {
+		scale := new(big.Int).Exp(big.NewInt(36), big.NewInt(int64(length-len(prefix))), nil)
+		low.Mul(value, scale)
+		high.Add(low, scale).Sub(high, big.NewInt(1))
+		found = low.Cmp(maxHexagon) <= 0
+	}
+	if !found {
+		return nil, fmt.Errorf("no hexagon in the library starts with %q", prefix)
+	}
+
+	// the page numbers in those hexagons
+	pagesPerHexagon := big.NewInt(int64(l.geometry.PagesPerHexagon()))
+	first := new(big.Int).Mul(low, pagesPerHexagon)
+	last := new(big.Int).Add(high, big.NewInt(1))
+	last.Mul(last, pagesPerHexagon).Sub(last, big.NewInt(1))
+	if maxPage := new(big.Int).Sub(l.pageCount, big.NewInt(1)); last.Cmp(maxPage) > 0 {
+		last = maxPage
+	}
+
+	// the fewest leading characters whose every continuation stays within range
+	charsPerPage := l.geometry.CharsPerPage()
+	for width := 1; width <= charsPerPage; width++ {
+		placeValue := new(big.Int).Exp(l.base, big.NewInt(int64(charsPerPage-width)), nil)
+		leading := new(big.Int).Add(first, placeValue)
+		leading.Sub(leading, big.NewInt(1)).Div(leading, placeValue)
+		end := new(big.Int).Add(leading, big.NewInt(1))
+		end.Mul(end, placeValue).Sub(end, big.NewInt(1))
+		if end.Cmp(last) <= 0 {
+			chars := make([]rune, width)
+			l.writeDigits(chars, leading)
+			return chars, nil
+		}
+	}
+	return nil, fmt.Errorf("no page starts a hexagon with %q", prefix)
+}
+
+// vanityCandidates lists the candidate places of the position that start text of the
+// given length below the first top characters of the page
+func (l Library) vanityCandidates(length, top int, p position) []int {
+	candidates := []int{}
+	for candidate := range p.candidates(length, l.geometry) {
+		if p.offset(candidate, length, l.geometry) >= top {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// vanityCount is the number of pages holding text of the given length below the
+// characters spelling config's prefix
+func (l Library) vanityCount(length int, config searchConfig) *big.Int {
+	top, err := l.hexagonPrefixChars(config.prefix)
+	if err != nil {
+		return big.NewInt(0)
+	}
+	count := big.NewInt(int64(len(l.vanityCandidates(length, len(top), config.position))))
+	if config.fill != FillBlank {
+		free := big.NewInt(int64(l.geometry.CharsPerPage() - length - len(top)))
+		count.Mul(count, new(big.Int).Exp(l.base, free, nil))
+	}
+	return count
+}
@@ -0,0 +1,114 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING searches for hexagons starting with a prefix
+*/
+
+func TestSearchWithHexagonPrefix(t *testing.T) {
+	library := newTestLibrary(t)
+	for _, prefix := range []string{"babel", "z", "1", "abc123", "zzzzzzzz"} {
+		results, err := library.SearchPaginated(searchText, 0, 10, WithHexagonPrefix(prefix))
+		if err != nil {
+			t.Fatalf("%s: search failed: %v", prefix, err)
+		}
+		if err := assertSearchResults(library, results); err != nil {
+			t.Errorf("%s: results assertion failed: %v", prefix, err)
+		}
+		seen := map[string]bool{}
+		for _, result := range results {
+			if !strings.HasPrefix(result.Location.Hexagon, prefix) {
+				t.Errorf("%s: got hexagon %s...", prefix, result.Location.Hexagon[:len(prefix)])
+			}
+			seen[result.Location.String()] = true
+		}
+		if len(seen) != len(results) {
+			t.Errorf("%s: got %d distinct pages from %d variants", prefix, len(seen), len(results))
+		}
+	}
+}
+
+func TestSearchWithHexagonPrefixAndOptions(t *testing.T) {
+	library := newTestLibrary(t)
+	opts := []SearchOption{WithHexagonPrefix("babel"), WithPage(7), WithExcluded("bab"), WithFill(FillEnglish)}
+	results, err := library.SearchPaginated(searchText, 0, 10, opts...)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if err := assertSearchResults(library, results); err != nil {
+		t.Errorf("results assertion failed: %v", err)
+	}
+	for _, result := range results {
+		if !strings.HasPrefix(result.Location.Hexagon, "babel") || result.Location.Page != 7 {
+			t.Errorf("got location %s, want hexagon babel... page 7", result.Location)
+		}
+	}
+}
+
+func TestSearchWithHexagonPrefixLeavesTheLeadingCharacters(t *testing.T) {
+	library := newTestLibrary(t)
+	top, err := library.hexagonPrefixChars("babel")
+	if err != nil {
+		t.Fatalf("failed to spell the prefix: %v", err)
+	}
+	// the first line holds the prefix's characters, text starting a line goes on any other
+	if got := library.ReachableCount("hello", WithHexagonPrefix("babel"), WithLineStart(), WithFill(FillBlank)); got != 39 {
+		t.Errorf("got %d reachable pages, want 39", got)
+	}
+	results, err := library.SearchPaginated("hello", 0, 39, WithHexagonPrefix("babel"), WithLineStart(), WithFill(FillBlank))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Offset < len(top) {
+			t.Errorf("text at offset %d overlaps the %d characters spelling the prefix", result.Offset, len(top))
+		}
+	}
+}
+
+func TestSearchWithConflictingHexagonPrefix(t *testing.T) {
+	library := newTestLibrary(t)
+	_, err := library.SearchPaginated(searchText, 0, 1, WithHexagonPrefix("babel"), WithPosition(1, 3))
+	if err == nil || !strings.Contains(err.Error(), "overlaps") {
+		t.Errorf("got %v, want the text overlapping the prefix", err)
+	}
+	text := strings.Repeat("a", DefaultGeometry.CharsPerPage()-2)
+	if _, err := library.SearchPaginated(text, 0, 1, WithHexagonPrefix("babel")); err == nil {
+		t.Errorf("got nil, want no room for the text")
+	}
+	// clear of the prefix's characters
+	if _, err := library.SearchPaginated(searchText, 0, 1, WithHexagonPrefix("babel"), WithPosition(2, 1)); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
+
+func TestSearchWithInvalidHexagonPrefix(t *testing.T) {
+	tests := map[string][]SearchOption{
+		"not base 36":   {WithHexagonPrefix("ba-el")},
+		"upper case":    {WithHexagonPrefix("Babel")},
+		"leading zero":  {WithHexagonPrefix("0abc")},
+		"zero":          {WithHexagonPrefix("0")},
+		"past the end":  {WithHexagonPrefix(strings.Repeat("z", 4000))},
+		"layout":        {WithHexagonPrefix("babel"), WithLayout(LayoutVertical)},
+		"word wrap":     {WithHexagonPrefix("babel"), WithWordWrap()},
+		"wildcards":     {WithHexagonPrefix("babel"), WithWildcards()},
+		"short address": {WithHexagonPrefix("babel"), WithShortestAddress()},
+	}
+	library := newTestLibrary(t)
+	for name, opts := range tests {
+		if _, err := library.SearchPaginated(searchText, 0, 1, opts...); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+	scrambled := newTestLibrary(t, WithScrambling("vanity"))
+	if _, err := scrambled.SearchPaginated(searchText, 0, 1, WithHexagonPrefix("babel")); err == nil {
+		t.Errorf("scrambled: got nil, expected err")
+	}
+	if _, err := library.SearchRun(searchText, 0, WithHexagonPrefix("babel")); err == nil {
+		t.Errorf("run: got nil, expected err")
+	}
+}
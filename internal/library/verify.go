@@ -0,0 +1,41 @@
+package library
+
+import (
+	"slices"
+	"unicode"
+)
+
+// Verify finds every place text appears on the page at location, in page order. Matches
+// ignore case and run on from the end of one line to the start of the next, so line
+// breaks in text are ignored and a text copied from a page as printed in lines matches
+// where it sits. Overlapping matches are all reported. A page not holding text has no
+// matches and no error.
+func (l Library) Verify(location *Location, text string) ([]Match, error) {
+	text = stripLineBreaks(text)
+	if err := l.validateText(text); err != nil {
+		return nil, err
+	}
+	page, err := l.Browse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	pageRunes := lowerRunes(page)
+	textRunes := lowerRunes(l.charset.Fold(text))
+	matches := []Match{}
+	for offset := 0; offset+len(textRunes) <= len(pageRunes); offset++ {
+		if slices.Equal(pageRunes[offset:offset+len(textRunes)], textRunes) {
+			matches = append(matches, l.newMatch(offset, len(textRunes)))
+		}
+	}
+	return matches, nil
+}
+
+// lowerRunes is text's characters in lower case, for matching regardless of case
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
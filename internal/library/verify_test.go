@@ -0,0 +1,110 @@
+package library
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING verification of text at an address
+*/
+
+func TestVerifyFindsSearchResults(t *testing.T) {
+	for _, library := range []*Library{newTestLibrary(t), newTestLibrary(t, WithScrambling(scrambleKey))} {
+		results, err := library.SearchPaginated(searchText, 0, 5)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		for _, result := range results {
+			matches, err := library.Verify(result.Location, searchText)
+			if err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			if !containsMatch(matches, result.Match) {
+				t.Errorf("got matches %v, want one at line %d, column %d", matches, result.Line, result.Column)
+			}
+		}
+	}
+}
+
+func TestVerifyMatchesAcrossLinesAndCase(t *testing.T) {
+	library := newTestLibrary(t)
+	// text running from the last columns of line 1 onto line 2
+	results, err := library.SearchPaginated(searchText, 0, 1, WithPosition(1, 76))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	location := results[0].Location
+	for _, text := range []string{searchText, "HELLO World", "hello\n world", "hell\r\no world"} {
+		matches, err := library.Verify(location, text)
+		if err != nil {
+			t.Fatalf("%q: failed to verify: %v", text, err)
+		}
+		want := Match{Offset: 75, Line: 1, Column: 76, Length: len(searchText)}
+		if !containsMatch(matches, want) {
+			t.Errorf("%q: got matches %v, want %v", text, matches, want)
+		}
+	}
+
+	// a mixed case charset matches either case too
+	alphanumeric := newTestLibrary(t, WithCharset(CharsetAlphanumeric))
+	results, err = alphanumeric.SearchPaginated("Hello World", 0, 1)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if matches, err := alphanumeric.Verify(results[0].Location, "hELLO wORLD"); err != nil || !containsMatch(matches, results[0].Match) {
+		t.Errorf("got matches %v and err %v, want %v", matches, err, results[0].Match)
+	}
+}
+
+func TestVerifyReportsEveryMatch(t *testing.T) {
+	library := newTestLibrary(t)
+	charsPerPage := DefaultGeometry.CharsPerPage()
+	location, err := library.Locate(strings.Repeat("ab", charsPerPage/2))
+	if err != nil {
+		t.Fatalf("failed to locate: %v", err)
+	}
+	matches, err := library.Verify(location, "abab")
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if len(matches) != charsPerPage/2-1 {
+		t.Errorf("got %d matches, want %d", len(matches), charsPerPage/2-1)
+	}
+	for i, match := range matches {
+		if match.Offset != 2*i {
+			t.Errorf("match %d at offset %d, want %d", i, match.Offset, 2*i)
+		}
+	}
+
+	matches, err = library.Verify(location, "ba.")
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("got %d matches, want none", len(matches))
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	library := newTestLibrary(t)
+	location := &Location{Hexagon: "3a7f", Wall: 2, Shelf: 3, Book: 15, Page: 204}
+	for _, text := range []string{"", "\n", "hello!", strings.Repeat("a", DefaultGeometry.CharsPerPage()+1)} {
+		if _, err := library.Verify(location, text); err == nil {
+			t.Errorf("%q: got nil, expected err", text)
+		}
+	}
+	past := &Location{Hexagon: library.MaxHexagon().Text(36) + "0", Wall: 0, Shelf: 0, Book: 0, Page: 1}
+	if _, err := library.Verify(past, searchText); err == nil {
+		t.Errorf("past the end: got nil, expected err")
+	}
+}
+
+func containsMatch(matches []Match, want Match) bool {
+	for _, match := range matches {
+		if match == want {
+			return true
+		}
+	}
+	return false
+}
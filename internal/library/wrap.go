@@ -0,0 +1,147 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// WithWordWrap wraps the text at word boundaries instead of breaking words at the end of a
+// line: a word that would cross the line's end moves to the start of the next line, the
+// rest of the line padded with spaces. Results match the text as wrapped, padding included.
+// Word wrap applies to LayoutRun without wildcards, and cannot be centred.
+func WithWordWrap() SearchOption {
+	return func(c *searchConfig) {
+		c.wordWrap = true
+	}
+}
+
+// wrap lays text out from the given column, counting from 0, moving words that do not fit
+// on to the next line. Each word keeps the space after it on its own line, so words never
+// run into each other when the page is read end to end. Returns false when the first word
+// does not fit on the first line, or a word with its space does not fit on any.
+func wrap(text []rune, column, charsPerLine int) ([]rune, bool) {
+	wrapped := make([]rune, 0, len(text))
+	words := splitWords(text)
+	for i, word := range words {
+		width := len(word)
+		if i < len(words)-1 {
+			width++
+		}
+		if column+width > charsPerLine {
+			if i == 0 || width > charsPerLine {
+				return nil, false
+			}
+			for range charsPerLine - column {
+				wrapped = append(wrapped, ' ')
+			}
+			column = 0
+		}
+		wrapped = append(wrapped, word...)
+		if i < len(words)-1 {
+			wrapped = append(wrapped, ' ')
+		}
+		column += width
+	}
+	return wrapped, true
+}
+
+// splitWords splits text at each space, consecutive spaces leaving empty words between them
+func splitWords(text []rune) [][]rune {
+	words := [][]rune{{}}
+	for _, char := range text {
+		if char == ' ' {
+			words = append(words, []rune{})
+			continue
+		}
+		words[len(words)-1] = append(words[len(words)-1], char)
+	}
+	return words
+}
+
+// wrapping lists the page offsets word wrapped text can start at and the length of the
+// text wrapped from each of them
+type wrapping struct {
+	starts  []int
+	lengths []int
+}
+
+// wrapStarts works out where word wrapped text can start from the position. It wraps the
+// text from every offset the position allows, so searches work it out once.
+func (p position) wrapStarts(text []rune, geometry Geometry) *wrapping {
+	charsPerLine := geometry.CharsPerLine
+	offsets := []int{}
+	switch p.kind {
+	case positionExact:
+		offsets = append(offsets, (p.line-1)*charsPerLine+p.column-1)
+	case positionLineStart:
+		for line := range geometry.LinesPerPage {
+			offsets = append(offsets, line*charsPerLine)
+		}
+	default:
+		for offset := range geometry.CharsPerPage() {
+			offsets = append(offsets, offset)
+		}
+	}
+
+	found := &wrapping{starts: []int{}, lengths: []int{}}
+	for _, offset := range offsets {
+		wrapped, ok := wrap(text, offset%charsPerLine, charsPerLine)
+		if ok && offset+len(wrapped) <= geometry.CharsPerPage() {
+			found.starts = append(found.starts, offset)
+			found.lengths = append(found.lengths, len(wrapped))
+		}
+	}
+	return found
+}
+
+// validateWrap checks text can be word wrapped somewhere on a page at config's position,
+// keeping where it can start in config
+func (l Library) validateWrap(text string, config *searchConfig) error {
+	switch {
+	case config.layout != LayoutRun:
+		return fmt.Errorf("word wrap does not apply to the %s layout", config.layout)
+	case config.wildcards:
+		return errors.New("word wrap does not apply to text with wildcards")
+	case config.position.kind == positionCentred:
+		return errors.New("word wrapped text cannot be centred")
+	case !l.charset.Contains(' '):
+		return fmt.Errorf("charset %s has no space to wrap words with", l.charset.Name())
+	}
+	if err := config.position.validate(config.textLength(text), l.geometry); err != nil {
+		return err
+	}
+
+	textRunes := []rune(l.charset.Fold(text))
+	if slices.ContainsFunc(splitWords(textRunes), func(word []rune) bool {
+		return len(word) > l.geometry.CharsPerLine
+	}) {
+		return fmt.Errorf("text holds a word longer than a line of %d characters", l.geometry.CharsPerLine)
+	}
+	config.wrapping = config.position.wrapStarts(textRunes, l.geometry)
+	if len(config.wrapping.starts) == 0 {
+		return errors.New("word wrapped text does not fit on a page")
+	}
+	return nil
+}
+
+// wrapCount is the number of pages holding the word wrapped text at one of its starts,
+// counting a page once per start holding it like position.count
+func (l Library) wrapCount(config searchConfig) *big.Int {
+	if config.fill == FillBlank {
+		return big.NewInt(int64(len(config.wrapping.starts)))
+	}
+	// wrapped texts of the same length leave the same number of free characters
+	byLength := map[int]int64{}
+	for _, length := range config.wrapping.lengths {
+		byLength[length]++
+	}
+	count := new(big.Int)
+	for length, n := range byLength {
+		free := big.NewInt(int64(l.geometry.CharsPerPage() - length))
+		pages := new(big.Int).Exp(l.base, free, nil)
+		count.Add(count, pages.Mul(pages, big.NewInt(n)))
+	}
+	return count
+}
@@ -0,0 +1,113 @@
+package library
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+/*
+	TESTING word wrapped search
+*/
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text   string
+		column int
+		want   string
+		fits   bool
+	}{
+		{"hello you", 0, "hello you", true},
+		// you moves to the next line, the rest of the first padded with spaces
+		{"hello you", 2, "hello   you", true},
+		// the space after hello ends the line
+		{"hello you", 4, "hello you", true},
+		// hello does not fit with its space
+		{"hello you", 5, "", false},
+		{"ab  cd", 5, "ab   cd", true},
+		{"hello", 5, "hello", true},
+	}
+	for _, test := range tests {
+		wrapped, fits := wrap([]rune(test.text), test.column, 10)
+		if fits != test.fits {
+			t.Errorf("%q from column %d: got fits %t, want %t", test.text, test.column, fits, test.fits)
+			continue
+		}
+		if fits && string(wrapped) != test.want {
+			t.Errorf("%q from column %d: got %q, want %q", test.text, test.column, wrapped, test.want)
+		}
+	}
+}
+
+func TestSearchWithWordWrap(t *testing.T) {
+	library := newTestLibrary(t)
+	charsPerLine := DefaultGeometry.CharsPerLine
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog ", 4)
+
+	results, err := library.SearchPaginated(text, 0, 20, WithWordWrap())
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	for _, result := range results {
+		page, err := library.Browse(result.Location)
+		if err != nil {
+			t.Fatalf("failed to browse: %v", err)
+		}
+		match := page[result.Offset : result.Offset+result.Length]
+		if match != result.Snippet.Match {
+			t.Errorf("snippet %q does not hold the match %q", result.Snippet.Match, match)
+		}
+		if got := strings.Join(strings.Fields(match), " "); got != strings.TrimSpace(text) {
+			t.Errorf("match %q does not hold the text's words", match)
+		}
+
+		// no line of the match may end in the middle of a word
+		for end := (result.Offset/charsPerLine + 1) * charsPerLine; end < result.Offset+result.Length; end += charsPerLine {
+			if page[end-1] != ' ' && page[end] != ' ' {
+				t.Errorf("word split over the line ending at offset %d: %q", end, page[end-10:end+10])
+			}
+		}
+	}
+}
+
+func TestSearchWithWordWrapWalksBlankPages(t *testing.T) {
+	library := newTestLibrary(t)
+	count := library.ReachableCount(searchText, WithWordWrap(), WithFill(FillBlank), WithLineStart())
+	if count != DefaultGeometry.LinesPerPage {
+		t.Fatalf("got %d reachable pages, want %d", count, DefaultGeometry.LinesPerPage)
+	}
+	results, err := library.SearchPaginated(searchText, 0, count, WithWordWrap(), WithFill(FillBlank))
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	offsets := []int{}
+	for _, result := range results {
+		offsets = append(offsets, result.Offset)
+	}
+	// variants walk through the starts in order, skipping those splitting hello
+	charsPerLine := DefaultGeometry.CharsPerLine
+	if want := []int{0, 1, 2}; !slices.Equal(offsets[:3], want) {
+		t.Errorf("got offsets %v, want them to start %v", offsets[:3], want)
+	}
+	if slices.Contains(offsets, charsPerLine-4) {
+		t.Errorf("hello should never start %d characters before the end of a line", 4)
+	}
+}
+
+func TestSearchWithInvalidWordWrap(t *testing.T) {
+	library := newTestLibrary(t)
+	tests := map[string][]SearchOption{
+		"layout":    {WithWordWrap(), WithLayout(LayoutVertical)},
+		"wildcards": {WithWordWrap(), WithWildcards()},
+		"centred":   {WithWordWrap(), WithCentred()},
+		"position":  {WithWordWrap(), WithPosition(1, 78)},
+	}
+	for name, opts := range tests {
+		if _, err := library.SearchPaginated(searchText, 0, 1, opts...); err == nil {
+			t.Errorf("%s: got nil, expected err", name)
+		}
+	}
+	if _, err := library.SearchPaginated(strings.Repeat("a", 81), 0, 1, WithWordWrap()); err == nil {
+		t.Errorf("word longer than a line: got nil, expected err")
+	}
+}
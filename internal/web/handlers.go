@@ -1,10 +1,12 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"html"
 	"html/template"
 	"log"
+	"math/big"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -27,29 +29,89 @@ func NewHandler(lib *library.Library, logger *log.Logger) *Handler {
 }
 
 func (h *Handler) SearchForm(c *gin.Context) {
-	c.HTML(http.StatusOK, "search.tmpl", gin.H{
-		"title": "Search",
-	})
+	c.HTML(http.StatusOK, "search.tmpl", h.searchData(searchForm{
+		Position: "anywhere",
+		Fill:     string(library.FillRandom),
+		Layout:   string(library.LayoutRun),
+	}))
+}
+
+// searchData holds what the search template needs to render the form itself
+func (h *Handler) searchData(form searchForm) gin.H {
+	geometry := h.lib.Geometry()
+	return gin.H{
+		"title":        "Search",
+		"form":         form,
+		"charsPerPage": geometry.CharsPerPage(),
+		"linesPerPage": geometry.LinesPerPage,
+		"charsPerLine": geometry.CharsPerLine,
+		// ranges of the shelving fields
+		"wallsPerHexagon": geometry.WallsPerHexagon,
+		"shelvesPerWall":  geometry.ShelvesPerWall,
+		"booksPerShelf":   geometry.BooksPerShelf,
+		"pagesPerBook":    geometry.PagesPerBook,
+	}
 }
 
 func (h *Handler) Home(c *gin.Context) {
 	h.logger.Println("serving home page")
 
+	charset := h.lib.Charset()
 	c.HTML(http.StatusOK, "home.tmpl", gin.H{
-		"title": "Library of Babel",
+		"title":        "Library of Babel",
+		"charsetSize":  charset.Size(),
+		"symbols":      formatSymbols(charset.Symbols()),
+		"geometry":     h.lib.Geometry(),
+		"charsPerPage": h.lib.Geometry().CharsPerPage(),
 	})
 }
 
+// formatSymbols lists charset symbols separated by spaces, spelling out the space symbol
+func formatSymbols(symbols []rune) string {
+	names := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		if symbol == ' ' {
+			names = append(names, "[space]")
+			continue
+		}
+		names = append(names, string(symbol))
+	}
+	return strings.Join(names, " ")
+}
+
 func (h *Handler) SearchPost(c *gin.Context) {
-	text := c.PostForm("text")
+	form := newSearchForm(c)
+	text := form.text(c.PostForm("text"))
 	pageStr := c.DefaultPostForm("page", "1")
+	data := h.searchData(form)
 
 	if text == "" {
 		h.logger.Println("empty search query")
-		c.HTML(http.StatusBadRequest, "search.tmpl", gin.H{
-			"title": "Search",
-			"error": "Please enter text to search",
-		})
+		data["error"] = "Please enter text to search"
+		c.HTML(http.StatusBadRequest, "search.tmpl", data)
+		return
+	}
+	data["query"] = text
+
+	normalized, changes, err := form.normalize(h.lib, text)
+	if err != nil {
+		h.logger.Printf("invalid search text: %v", err)
+		data["error"] = fmt.Sprintf("Search failed: %v", err)
+		var invalid *library.InvalidCharacterError
+		if errors.As(err, &invalid) {
+			data["invalidText"] = highlightInvalid(text, invalid.Index)
+		}
+		c.HTML(http.StatusBadRequest, "search.tmpl", data)
+		return
+	}
+	data["changes"] = changes
+	text = normalized
+
+	opts, err := form.options()
+	if err != nil {
+		h.logger.Printf("invalid search options: %v", err)
+		data["error"] = fmt.Sprintf("Invalid search options: %v", err)
+		c.HTML(http.StatusBadRequest, "search.tmpl", data)
 		return
 	}
 
@@ -63,29 +125,48 @@ func (h *Handler) SearchPost(c *gin.Context) {
 	const resultsPerPage = 20
 	offset := (page - 1) * resultsPerPage
 
-	locations, err := h.lib.SearchPaginated(text, offset, resultsPerPage)
+	results, err := h.lib.SearchPaginated(text, offset, resultsPerPage, opts...)
 	if err != nil {
 		h.logger.Printf("search failed: %v", err)
-		c.HTML(http.StatusInternalServerError, "search.tmpl", gin.H{
-			"title": "Search",
-			"error": "Search failed",
-		})
+		data["error"] = fmt.Sprintf("Search failed: %v", err)
+		c.HTML(http.StatusBadRequest, "search.tmpl", data)
+		return
+	}
+	results, err = form.arrange(results)
+	if err != nil {
+		h.logger.Printf("invalid search options: %v", err)
+		data["error"] = fmt.Sprintf("Invalid search options: %v", err)
+		c.HTML(http.StatusBadRequest, "search.tmpl", data)
 		return
 	}
 
-	totalCount := h.lib.GetOccurrenceCount(text)
-	totalPages := (totalCount + resultsPerPage - 1) / resultsPerPage
+	reachableCount := h.lib.ReachableCount(text, opts...)
+	totalPages := (reachableCount + resultsPerPage - 1) / resultsPerPage
 
-	c.HTML(http.StatusOK, "search.tmpl", gin.H{
-		"title":       "Search Results",
-		"query":       text,
-		"locations":   locations,
-		"total":       totalCount,
-		"currentPage": page,
-		"totalPages":  totalPages,
-		"hasNext":     page < totalPages,
-		"hasPrev":     page > 1,
-	})
+	data["title"] = "Search Results"
+	data["results"] = results
+	// occurrence counts only cover text without wildcards written as a single run
+	if form.Layout == string(library.LayoutRun) && !form.Wildcards {
+		data["total"] = h.lib.GetOccurrenceCount(text)
+	}
+	data["reachable"] = reachableCount
+	data["base"] = h.lib.Charset().Size()
+	data["currentPage"] = page
+	data["totalPages"] = totalPages
+	data["hasNext"] = page < totalPages
+	data["hasPrev"] = page > 1
+	c.HTML(http.StatusOK, "search.tmpl", data)
+}
+
+// highlightInvalid marks the character at index of text, counting characters from 0
+func highlightInvalid(text string, index int) template.HTML {
+	runes := []rune(text)
+	if index < 0 || index >= len(runes) {
+		return template.HTML(html.EscapeString(text)) //nolint:gosec
+	}
+	return template.HTML(html.EscapeString(string(runes[:index])) + //nolint:gosec
+		"<mark>" + html.EscapeString(string(runes[index])) + "</mark>" +
+		html.EscapeString(string(runes[index+1:])))
 }
 
 func (h *Handler) BrowseForm(c *gin.Context) {
@@ -97,6 +178,16 @@ func (h *Handler) BrowseForm(c *gin.Context) {
 func (h *Handler) Browse(c *gin.Context) {
 	locationStr := c.PostForm("location")
 	query := c.PostForm("query")
+	// position of a search result's match, when coming from the search results
+	matchOffset, offsetErr := strconv.Atoi(c.PostForm("offset"))
+	matchLength, lengthErr := strconv.Atoi(c.PostForm("length"))
+	// cells of a match written in a layout other than a single run, comma separated
+	cells := map[int]bool{}
+	for cellStr := range strings.SplitSeq(c.PostForm("cells"), ",") {
+		if cell, err := strconv.Atoi(cellStr); err == nil {
+			cells[cell] = true
+		}
+	}
 
 	// check if individual components are provided (from jump-to-page form)
 	if locationStr == "" {
@@ -120,12 +211,20 @@ func (h *Handler) Browse(c *gin.Context) {
 		return
 	}
 
-	location, err := library.LocationFromString(locationStr)
+	location, err := h.lib.LocationFromAddress(locationStr, browseCodec(c))
 	if err != nil {
 		h.logger.Printf("invalid location: %s - %v", locationStr, err)
+		errorMessage := "Invalid location format"
+		var rangeErr *library.OutOfRangeError
+		if errors.As(err, &rangeErr) {
+			errorMessage = "Location is outside the library"
+		}
+		if errors.Is(err, library.ErrCheckDigits) {
+			errorMessage = "Location does not match its check digits, it was likely mistyped"
+		}
 		c.HTML(http.StatusBadRequest, "browse.tmpl", gin.H{
 			"title": "Browse",
-			"error": "Invalid location format",
+			"error": errorMessage,
 		})
 		return
 	}
@@ -142,12 +241,24 @@ func (h *Handler) Browse(c *gin.Context) {
 		return
 	}
 
-	formattedContent := formatPageContent(content)
+	charsPerLine := h.lib.Geometry().CharsPerLine
+	formattedContent := formatPageContent(content, charsPerLine)
 
 	var displayContent template.HTML
-	if query != "" {
+	switch {
+	case len(cells) > 0:
+		highlighted := highlightCells(content, charsPerLine, func(i int) bool {
+			return cells[i]
+		})
+		displayContent = template.HTML(highlighted) //nolint:gosec
+	case offsetErr == nil && lengthErr == nil && matchLength > 0:
+		highlighted := highlightCells(content, charsPerLine, func(i int) bool {
+			return i >= matchOffset && i < matchOffset+matchLength
+		})
+		displayContent = template.HTML(highlighted) //nolint:gosec
+	case query != "":
 		displayContent = template.HTML(highlightText(formattedContent, query)) //nolint:gosec
-	} else {
+	default:
 		displayContent = template.HTML(html.EscapeString(formattedContent)) //nolint:gosec
 	}
 
@@ -156,14 +267,36 @@ func (h *Handler) Browse(c *gin.Context) {
 		"location":       location,
 		"displayContent": displayContent,
 		"hasQuery":       query != "",
-		"nextLocation":   location.Next(),
-		"prevLocation":   location.Previous(),
+		"nextLocation":   h.neighbour(location, 1),
+		"prevLocation":   h.neighbour(location, -1),
+		"pagesPerBook":   h.lib.Geometry().PagesPerBook,
 	})
 }
 
-// formatPageContent formats content to have 80 characters per line (40 lines total)
-func formatPageContent(content string) string {
-	const charsPerLine = 80
+// browseCodec is the address format chosen on the browse form, base-36 hexagons when
+// none is, as the navigation forms post
+func browseCodec(c *gin.Context) library.AddressCodec {
+	codec, err := library.AddressCodecByName(c.PostForm("format"))
+	if err != nil {
+		codec = library.Base36Codec
+	}
+	if c.PostForm("check_digits") != "" {
+		codec = library.WithCheckDigits(codec)
+	}
+	return codec
+}
+
+// neighbour is the page offset pages from location, nil past either end of the library
+func (h *Handler) neighbour(location *library.Location, offset int64) *library.Location {
+	neighbour, err := h.lib.Advance(location, big.NewInt(offset))
+	if err != nil {
+		return nil
+	}
+	return neighbour
+}
+
+// formatPageContent breaks content into lines of charsPerLine characters (80 in the default geometry)
+func formatPageContent(content string, charsPerLine int) string {
 	var formatted strings.Builder
 
 	runes := []rune(content)
@@ -178,6 +311,38 @@ func formatPageContent(content string) string {
 	return formatted.String()
 }
 
+// Breaks content into lines like formatPageContent, wrapping the characters at the
+// indexes reported by marked in HTML mark tags. Marks are closed at the end of each line.
+func highlightCells(content string, charsPerLine int, marked func(int) bool) string {
+	var highlighted strings.Builder
+	open := false
+
+	runes := []rune(content)
+	for i, char := range runes {
+		if i > 0 && i%charsPerLine == 0 {
+			if open {
+				highlighted.WriteString("</mark>")
+				open = false
+			}
+			highlighted.WriteString("\n")
+		}
+		if isMarked := marked(i); isMarked != open {
+			if isMarked {
+				highlighted.WriteString("<mark>")
+			} else {
+				highlighted.WriteString("</mark>")
+			}
+			open = isMarked
+		}
+		highlighted.WriteString(html.EscapeString(string(char)))
+	}
+	if open {
+		highlighted.WriteString("</mark>")
+	}
+
+	return highlighted.String()
+}
+
 // Wraps the query text in the content with HTML mark tags for highlighting
 // it handles multi-line matches by treating any whitespace in the query as matching any whitespace in the content
 func highlightText(content, query string) string {
@@ -200,9 +365,181 @@ func highlightText(content, query string) string {
 	return highlighted
 }
 
+func (h *Handler) LocateForm(c *gin.Context) {
+	c.HTML(http.StatusOK, "locate.tmpl", gin.H{
+		"title":        "Locate",
+		"charsPerPage": h.lib.Geometry().CharsPerPage(),
+	})
+}
+
+func (h *Handler) Locate(c *gin.Context) {
+	page := c.PostForm("page")
+	pad := c.PostForm("pad") != ""
+
+	locate := h.lib.Locate
+	if pad {
+		locate = h.lib.LocatePadded
+	}
+	location, err := locate(page)
+	if err != nil {
+		h.logger.Printf("locate failed: %v", err)
+		errorMessage := "Page contains characters outside the library charset"
+		var lengthErr *library.PageLengthError
+		if errors.As(err, &lengthErr) {
+			errorMessage = fmt.Sprintf("A page holds exactly %d characters, got %d",
+				lengthErr.Expected, lengthErr.Length)
+		}
+		c.HTML(http.StatusBadRequest, "locate.tmpl", gin.H{
+			"title":        "Locate",
+			"error":        errorMessage,
+			"page":         page,
+			"pad":          pad,
+			"charsPerPage": h.lib.Geometry().CharsPerPage(),
+		})
+		return
+	}
+
+	h.logger.Printf("located: %s", location.String())
+
+	content, err := h.lib.Browse(location)
+	if err != nil {
+		h.logger.Printf("browse failed for located page: %v", err)
+		c.HTML(http.StatusInternalServerError, "locate.tmpl", gin.H{
+			"title":        "Locate",
+			"error":        "Failed to load page",
+			"charsPerPage": h.lib.Geometry().CharsPerPage(),
+		})
+		return
+	}
+
+	formattedContent := formatPageContent(content, h.lib.Geometry().CharsPerLine)
+	displayContent := template.HTML(html.EscapeString(formattedContent)) //nolint:gosec
+
+	c.HTML(http.StatusOK, "browse.tmpl", gin.H{
+		"title":          "Located Page",
+		"location":       location,
+		"displayContent": displayContent,
+		"nextLocation":   h.neighbour(location, 1),
+		"prevLocation":   h.neighbour(location, -1),
+		"pagesPerBook":   h.lib.Geometry().PagesPerBook,
+	})
+}
+
+func (h *Handler) RunForm(c *gin.Context) {
+	c.HTML(http.StatusOK, "run.tmpl", gin.H{
+		"title": "Run",
+		"fill":  string(library.FillRandom),
+	})
+}
+
+// RunPost writes a long text over several pages and shows one of them, stepping through
+// the run by posting the same text again with the index of another page
+func (h *Handler) RunPost(c *gin.Context) {
+	text := c.PostForm("text")
+	fill := c.DefaultPostForm("fill", string(library.FillRandom))
+	variant, err := strconv.Atoi(c.DefaultPostForm("variant", "0"))
+	if err != nil || variant < 0 {
+		variant = 0
+	}
+	index, err := strconv.Atoi(c.DefaultPostForm("index", "1"))
+	if err != nil || index < 1 {
+		index = 1
+	}
+	data := gin.H{
+		"title":   "Run",
+		"text":    text,
+		"fill":    fill,
+		"variant": variant,
+	}
+
+	if text == "" {
+		h.logger.Println("empty run text")
+		data["error"] = "Please enter text to write"
+		c.HTML(http.StatusBadRequest, "run.tmpl", data)
+		return
+	}
+
+	run, err := h.lib.SearchRun(text, variant, library.WithFill(library.Fill(fill)))
+	if err != nil {
+		h.logger.Printf("run failed: %v", err)
+		data["error"] = fmt.Sprintf("Run failed: %v", err)
+		c.HTML(http.StatusBadRequest, "run.tmpl", data)
+		return
+	}
+	index = min(index, len(run.Pages))
+	page := run.Pages[index-1]
+
+	h.logger.Printf("browsing page %d of a %d page run: %s", index, len(run.Pages), page.Location.String())
+
+	content, err := h.lib.Browse(page.Location)
+	if err != nil {
+		h.logger.Printf("browse failed for run page: %v", err)
+		data["error"] = "Failed to load page"
+		c.HTML(http.StatusInternalServerError, "run.tmpl", data)
+		return
+	}
+	highlighted := highlightCells(content, h.lib.Geometry().CharsPerLine, func(i int) bool {
+		return i < page.Length
+	})
+
+	data["title"] = "Run Page"
+	data["run"] = run
+	data["index"] = index
+	data["location"] = page.Location
+	data["displayContent"] = template.HTML(highlighted) //nolint:gosec
+	data["hasPrev"] = index > 1
+	data["hasNext"] = index < len(run.Pages)
+	c.HTML(http.StatusOK, "run.tmpl", data)
+}
+
+// verifyRequest is the body of a verify request, posted as JSON or a form
+type verifyRequest struct {
+	Address string `json:"address" form:"address" binding:"required"`
+	Text    string `json:"text"    form:"text"    binding:"required"`
+}
+
+// verifyMatch is where a verified text sits on the page
+type verifyMatch struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Length int `json:"length"`
+}
+
+// Verify reports as JSON whether a text appears on the page at an address, and every
+// line and column it appears at
+func (h *Handler) Verify(c *gin.Context) {
+	var request verifyRequest
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "address and text are required"})
+		return
+	}
+	location, err := h.lib.LocationFromString(request.Address)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	matches, err := h.lib.Verify(location, request.Text)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Printf("verified %d matches at %s", len(matches), location)
+	found := make([]verifyMatch, len(matches))
+	for i, match := range matches {
+		found[i] = verifyMatch{Offset: match.Offset, Line: match.Line, Column: match.Column, Length: match.Length}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"address": location,
+		"found":   len(matches) > 0,
+		"matches": found,
+	})
+}
+
 func (h *Handler) RandomPage(c *gin.Context) {
 	h.logger.Println("generating random page")
-	location := library.RandomLocation()
+	location := h.lib.RandomLocation()
 
 	h.logger.Printf("random location: %s", location.String())
 
@@ -217,14 +554,15 @@ func (h *Handler) RandomPage(c *gin.Context) {
 		return
 	}
 
-	formattedContent := formatPageContent(content)
+	formattedContent := formatPageContent(content, h.lib.Geometry().CharsPerLine)
 	displayContent := template.HTML(html.EscapeString(formattedContent)) //nolint:gosec
 
 	c.HTML(http.StatusOK, "browse.tmpl", gin.H{
 		"title":          "Random Page",
 		"location":       location,
 		"displayContent": displayContent,
-		"nextLocation":   location.Next(),
-		"prevLocation":   location.Previous(),
+		"nextLocation":   h.neighbour(location, 1),
+		"prevLocation":   h.neighbour(location, -1),
+		"pagesPerBook":   h.lib.Geometry().PagesPerBook,
 	})
 }
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/c12i/babel-go/internal/library"
+	"github.com/gin-gonic/gin"
+)
+
+// searchForm holds the search options posted with the search form, carried along
+// by the pagination controls so every page of results is searched the same way
+type searchForm struct {
+	// anywhere, line-start, centre or exact
+	Position string
+	Line     string
+	Column   string
+	// random, blank or english
+	Fill string
+	// set when ? and * in the text are wildcards
+	Wildcards bool
+	// texts the rest of the page must not contain, one per line
+	Exclude string
+	// run, vertical, diagonal or block
+	Layout string
+	// set when the text wraps at word boundaries
+	Wrap bool
+	// wall, shelf, book and page to keep results on, empty for any
+	Wall  string
+	Shelf string
+	Book  string
+	Page  string
+	// set when searching for the shortest addresses
+	Shortest bool
+	// base-36 prefix every result's hexagon starts with, empty for any
+	Prefix string
+	// set when each page of results is sorted by hexagon length
+	SortHexagon bool
+	// longest hexagon shown, empty for any
+	MaxHexagon string
+	// normalise to rewrite characters outside the charset, strict to reject them
+	Input string
+}
+
+func newSearchForm(c *gin.Context) searchForm {
+	return searchForm{
+		Position:    c.DefaultPostForm("position", "anywhere"),
+		Line:        c.PostForm("line"),
+		Column:      c.PostForm("column"),
+		Fill:        c.DefaultPostForm("fill", string(library.FillRandom)),
+		Wildcards:   c.PostForm("wildcards") != "",
+		Exclude:     c.PostForm("exclude"),
+		Layout:      c.DefaultPostForm("layout", string(library.LayoutRun)),
+		Wrap:        c.PostForm("wrap") != "",
+		Wall:        c.PostForm("wall"),
+		Shelf:       c.PostForm("shelf"),
+		Book:        c.PostForm("book"),
+		Page:        c.PostForm("shelved_page"),
+		Shortest:    c.PostForm("shortest") != "",
+		Prefix:      c.PostForm("hexagon_prefix"),
+		SortHexagon: c.PostForm("sort_hexagon") != "",
+		MaxHexagon:  c.PostForm("max_hexagon"),
+		Input:       c.DefaultPostForm("input", "normalise"),
+	}
+}
+
+// text normalises the searched text, keeping the line breaks of block text as single newlines
+func (f searchForm) text(text string) string {
+	if f.Layout == string(library.LayoutBlock) {
+		return strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	return text
+}
+
+// normalize rewrites the text into the library's charset, or in strict input checks it
+// is already written in it, keeping wildcards and the line breaks of block text
+func (f searchForm) normalize(lib *library.Library, text string) (string, []library.Change, error) {
+	opts := []library.NormalizeOption{}
+	if f.Input == "strict" {
+		opts = append(opts, library.WithStrict())
+	}
+	if f.Wildcards {
+		opts = append(opts, library.WithKept("?*"))
+	}
+	if f.Layout == string(library.LayoutBlock) {
+		opts = append(opts, library.WithKept("\n"))
+	}
+	return lib.Normalize(text, opts...)
+}
+
+// options converts the form into library search options
+func (f searchForm) options() ([]library.SearchOption, error) {
+	opts := []library.SearchOption{
+		library.WithFill(library.Fill(f.Fill)),
+		library.WithLayout(library.Layout(f.Layout)),
+	}
+	if f.Wildcards {
+		opts = append(opts, library.WithWildcards())
+	}
+	if f.Wrap {
+		opts = append(opts, library.WithWordWrap())
+	}
+	if f.Shortest {
+		opts = append(opts, library.WithShortestAddress())
+	}
+	if f.Prefix != "" {
+		opts = append(opts, library.WithHexagonPrefix(f.Prefix))
+	}
+	for line := range strings.Lines(f.Exclude) {
+		if excluded := strings.TrimRight(line, "\r\n"); excluded != "" {
+			opts = append(opts, library.WithExcluded(excluded))
+		}
+	}
+	shelving := []struct {
+		name  string
+		value string
+		with  func(int) library.SearchOption
+	}{
+		{"wall", f.Wall, library.WithWall},
+		{"shelf", f.Shelf, library.WithShelf},
+		{"book", f.Book, library.WithBook},
+		{"page", f.Page, library.WithPage},
+	}
+	for _, part := range shelving {
+		if part.value == "" {
+			continue
+		}
+		value, err := strconv.Atoi(part.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number, got %q", part.name, part.value)
+		}
+		opts = append(opts, part.with(value))
+	}
+	switch f.Position {
+	case "anywhere":
+	case "line-start":
+		opts = append(opts, library.WithLineStart())
+	case "centre":
+		opts = append(opts, library.WithCentred())
+	case "exact":
+		line, err := strconv.Atoi(f.Line)
+		if err != nil {
+			return nil, fmt.Errorf("line must be a number, got %q", f.Line)
+		}
+		column, err := strconv.Atoi(f.Column)
+		if err != nil {
+			return nil, fmt.Errorf("column must be a number, got %q", f.Column)
+		}
+		opts = append(opts, library.WithPosition(line, column))
+	default:
+		return nil, fmt.Errorf("unknown position %q", f.Position)
+	}
+	return opts, nil
+}
+
+// arrange sorts and filters a page of results by hexagon length as the form asks
+func (f searchForm) arrange(results []*library.SearchResult) ([]*library.SearchResult, error) {
+	if f.SortHexagon {
+		library.SortByHexagonLength(results)
+	}
+	if f.MaxHexagon == "" {
+		return results, nil
+	}
+	maxHexagon, err := strconv.Atoi(f.MaxHexagon)
+	if err != nil {
+		return nil, fmt.Errorf("hexagon length must be a number, got %q", f.MaxHexagon)
+	}
+	return slices.DeleteFunc(results, func(result *library.SearchResult) bool {
+		return result.HexagonLength() > maxHexagon
+	}), nil
+}
@@ -1,14 +1,14 @@
 package web
 
 import (
-	"fmt"
 	"html/template"
 	"log"
-	"math"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 
+	"github.com/c12i/babel-go/internal/library"
 	"github.com/gin-gonic/gin"
 )
 
@@ -47,13 +47,14 @@ func NewServer(handler *Handler, logger *log.Logger) *Server {
 			}
 			return string(result)
 		},
-		"toPowerOf2": func(n int) string {
-			if n <= 0 {
-				return "0"
+		"scientific": library.FormatScientific,
+		"power":      library.FormatPower,
+		"joinCells": func(cells []int) string {
+			joined := make([]string, len(cells))
+			for i, cell := range cells {
+				joined[i] = strconv.Itoa(cell)
 			}
-			// calculate log2 and round to nearest integer
-			exponent := int(math.Round(math.Log2(float64(n))))
-			return fmt.Sprintf("2^%d", exponent)
+			return strings.Join(joined, ",")
 		},
 	}
 	router.SetFuncMap(funcMap)
@@ -84,8 +85,15 @@ func NewServer(handler *Handler, logger *log.Logger) *Server {
 	router.POST("/search", handler.SearchPost)
 	router.GET("/browse", handler.BrowseForm)
 	router.POST("/browse", handler.Browse)
+	router.GET("/locate", handler.LocateForm)
+	router.POST("/locate", handler.Locate)
+	router.GET("/run", handler.RunForm)
+	router.POST("/run", handler.RunPost)
 	router.GET("/random", handler.RandomPage)
 
+	// json api
+	router.POST("/api/verify", handler.Verify)
+
 	return &Server{
 		router: router,
 		logger: logger,